@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// AsyncHandler 异步处理器，HandleAsync 应该立即返回，处理完成后调用一次 done，
+// 适用于调用外部服务等 IO 密集的处理步骤。
+type AsyncHandler interface {
+	HandleAsync(in interface{}, done func(out interface{}, err error))
+}
+
+// AsyncHandlerFunc function式AsyncHandler.
+type AsyncHandlerFunc func(in interface{}, done func(out interface{}, err error))
+
+// HandleAsync 实现AsyncHandler接口。
+func (af AsyncHandlerFunc) HandleAsync(in interface{}, done func(out interface{}, err error)) {
+	af(in, done)
+}
+
+// asyncStage 处理链中的异步处理器。
+type asyncStage struct {
+	ah  AsyncHandler
+	sem chan struct{} // 限制未完成的数量
+	mu  sync.Mutex    // 串行执行其后的处理器
+}
+
+// String 返回异步处理器的名称。
+func (as *asyncStage) String() string {
+	if s, ok := as.ah.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", as.ah)
+}
+
+// Handle 实现 Handler 接口，同步等待异步处理完成，用于不支持异步的场合（例如 Chain）。
+func (as *asyncStage) Handle(in interface{}) (interface{}, error) {
+	type result struct {
+		out interface{}
+		err error
+	}
+	ch := make(chan result, 1)
+	as.ah.HandleAsync(in, func(out interface{}, err error) {
+		select {
+		case ch <- result{out, err}:
+		default:
+		}
+	})
+	r := <-ch
+	return r.out, r.err
+}
+
+// AddAsyncHandler 添加异步处理器，最多同时有 maxOutstanding 条数据未完成，<= 0 时为 1。
+// 数据交给异步处理器后引擎立即拉取下一条数据，处理完成后在回调所在的 goroutine 中
+// 执行后面的处理器，这些处理器不会被并发调用，但数据的顺序可能改变。
+func (h *Handlers) AddAsyncHandler(ah AsyncHandler, maxOutstanding int) {
+	if maxOutstanding <= 0 {
+		maxOutstanding = 1
+	}
+	h.AddHandler(&asyncStage{
+		ah:  ah,
+		sem: make(chan struct{}, maxOutstanding),
+	})
+}
+
+// dispatchAsync 把 d 交给异步处理器，未完成的数量达到上限时阻塞。
+// 处理链写时复制，回调沿着 e 所在的处理链继续处理，不需要加锁。
+// fl 为数据所属的源的 flight，处理中产生的错误只记录在其中，由处理这个源的 worker 报告。
+func (h *Handlers) dispatchAsync(fl *flight, e *list.Element, as *asyncStage, d interface{}) {
+	as.sem <- struct{}{}
+	h.flightMu.Lock()
+	h.inFlight++
+	h.flightMu.Unlock()
+	fl.add()
+
+	var once sync.Once
+	done := func(out interface{}, err error) {
+		once.Do(func() {
+			if errors.Is(err, ErrSkip) {
+				err, out = nil, None
+			}
+			if err == nil {
+				as.mu.Lock()
+				err = h.emitFrom(fl, e.Next(), out)
+				as.mu.Unlock()
+			}
+			if err != nil {
+				fl.fail(err)
+			}
+			<-as.sem
+			h.release()
+			fl.done()
+		})
+	}
+	defer func() {
+		// HandleAsync panic 时转换为 *PanicError，尚未调用 done 时在这里结束这条数据，释放 sem 和 flight。
+		if r := recover(); r != nil {
+			done(nil, &PanicError{Handler: as.String(), Value: r, Stack: debug.Stack()})
+		}
+	}()
+	as.ah.HandleAsync(d, done)
+}
+
+// flight 一个源尚未处理完的异步和流水线中的数据，以及其中产生的第一个错误。
+// 同时处理多个源时只等待这个源的数据，不受其他源影响。方法可以在 nil 上调用。
+type flight struct {
+	wg  sync.WaitGroup
+	mu  sync.Mutex
+	err error
+}
+
+func (fl *flight) add() {
+	if fl != nil {
+		fl.wg.Add(1)
+	}
+}
+
+func (fl *flight) done() {
+	if fl != nil {
+		fl.wg.Done()
+	}
+}
+
+func (fl *flight) fail(err error) {
+	if fl == nil {
+		return
+	}
+	fl.mu.Lock()
+	if fl.err == nil {
+		fl.err = err
+	}
+	fl.mu.Unlock()
+}
+
+// wait 等待这个源的数据处理完毕，取出并清除其中产生的错误。
+// 只能在处理这个源的 goroutine 中调用，此时不会有新的数据从外部加入。
+func (fl *flight) wait() error {
+	if fl == nil {
+		return nil
+	}
+	fl.wg.Wait()
+	fl.mu.Lock()
+	err := fl.err
+	fl.err = nil
+	fl.mu.Unlock()
+	return err
+}
+
+// takeErr 不等待，取出并清除这个源已经产生的错误。
+func (fl *flight) takeErr() error {
+	if fl == nil {
+		return nil
+	}
+	fl.mu.Lock()
+	err := fl.err
+	fl.err = nil
+	fl.mu.Unlock()
+	return err
+}
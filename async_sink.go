@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy AsyncSink 队列已满时的处理方式。
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota // 阻塞直到队列有空位（默认）
+	OverflowDropNewest                       // 丢弃当前写入的数据
+	OverflowDropOldest                       // 丢弃队列中最早的数据
+)
+
+// AsyncSink 把任意输出变为异步：Write 把数据放入有界队列后立即返回，由后台的写入 goroutine 写入被包装的输出，
+// 这样慢的输出不会拖慢处理链。writers > 1 时被包装的输出会被并发调用，需要是并发安全的，且不保证写入顺序。
+// 后台写入的错误交给 OnError，第一个错误还会在之后的 Write、Flush 或 Close 中返回。
+type AsyncSink struct {
+	s        Sink
+	queue    chan interface{}
+	overflow OverflowPolicy
+
+	// OnError 后台写入失败时调用。
+	OnError func(out interface{}, err error)
+
+	enqMu   sync.Mutex // OverflowDropOldest 时保证出队和入队成对
+	pending sync.WaitGroup
+	workers sync.WaitGroup
+	closed  int32
+	dropped uint64
+
+	errMu sync.Mutex
+	err   error
+}
+
+// NewAsyncSink 包装输出 s，queueSize 为队列容量，writers 为写入 goroutine 的数量。
+func NewAsyncSink(s Sink, queueSize, writers int, overflow OverflowPolicy) *AsyncSink {
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	if writers <= 0 {
+		writers = 1
+	}
+	as := &AsyncSink{s: s, queue: make(chan interface{}, queueSize), overflow: overflow}
+	as.workers.Add(writers)
+	for i := 0; i < writers; i++ {
+		go as.work()
+	}
+	return as
+}
+
+func (as *AsyncSink) work() {
+	defer as.workers.Done()
+	for out := range as.queue {
+		if err := as.s.Write(out); err != nil {
+			as.errMu.Lock()
+			if as.err == nil {
+				as.err = err
+			}
+			as.errMu.Unlock()
+			if as.OnError != nil {
+				as.OnError(out, err)
+			}
+		}
+		as.pending.Done()
+	}
+}
+
+// takeErr 取出后台写入的第一个错误。
+func (as *AsyncSink) takeErr() error {
+	as.errMu.Lock()
+	defer as.errMu.Unlock()
+	err := as.err
+	as.err = nil
+	return err
+}
+
+// Write 实现 Sink 接口。
+func (as *AsyncSink) Write(out interface{}) error {
+	if atomic.LoadInt32(&as.closed) == 1 {
+		return errors.New("async sink: closed")
+	}
+	if err := as.takeErr(); err != nil {
+		return err
+	}
+	as.pending.Add(1)
+	switch as.overflow {
+	case OverflowDropNewest:
+		select {
+		case as.queue <- out:
+		default:
+			as.pending.Done()
+			atomic.AddUint64(&as.dropped, 1)
+		}
+	case OverflowDropOldest:
+		as.enqMu.Lock()
+		for {
+			select {
+			case as.queue <- out:
+				as.enqMu.Unlock()
+				return nil
+			default:
+			}
+			select {
+			case <-as.queue:
+				as.pending.Done()
+				atomic.AddUint64(&as.dropped, 1)
+			default:
+			}
+		}
+	default:
+		as.queue <- out
+	}
+	return nil
+}
+
+// Dropped 返回因为队列已满被丢弃的数据条数。
+func (as *AsyncSink) Dropped() uint64 {
+	return atomic.LoadUint64(&as.dropped)
+}
+
+// Flush 实现 Flusher 接口，等待队列中的数据全部写入后刷新被包装的输出。
+func (as *AsyncSink) Flush() error {
+	as.pending.Wait()
+	if err := as.takeErr(); err != nil {
+		return err
+	}
+	if f, ok := as.s.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close 实现 Sink 接口，写完队列中的数据后关闭被包装的输出。不能和 Write 并发调用。
+func (as *AsyncSink) Close() error {
+	if !atomic.CompareAndSwapInt32(&as.closed, 0, 1) {
+		return nil
+	}
+	close(as.queue)
+	as.workers.Wait()
+	err := as.takeErr()
+	if cerr := as.s.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"math/rand"
+	"time"
+)
+
+// BackoffFunc 根据重试次数（从 0 开始）返回下次重试前的等待时间。
+type BackoffFunc func(attempt int) time.Duration
+
+// NewBackoff 创建带抖动的指数退避，等待时间从 min 开始翻倍，最多为 max。
+// 实际等待时间在 [d/2, d) 之间随机，避免大量客户端同时重试。
+func NewBackoff(min, max time.Duration) BackoffFunc {
+	return func(attempt int) time.Duration {
+		d := min
+		for i := 0; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		if d <= 1 {
+			return d
+		}
+		half := d / 2
+		return half + time.Duration(rand.Int63n(int64(d-half)))
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// Batcher 把数据攒成批，以 []interface{} 交给下一个处理器，适用于批量写入数据库、调用批量接口等。
+// 批中的数据达到 size 条，或者距批中第一条数据到达超过 flushInterval 时输出该批；
+// 时间到期在处理后续数据或收到控制标记（例如心跳）时检查，源结束时总是输出剩余的数据。
+type Batcher struct {
+	size     int
+	interval time.Duration
+	mu       sync.Mutex
+	batch    []interface{}
+	first    time.Time // 批中第一条数据到达的时间
+}
+
+// BatchHandler 新建攒批处理器，size <= 0 表示不限条数，flushInterval <= 0 表示不按时间输出。
+func BatchHandler(size int, flushInterval time.Duration) *Batcher {
+	return &Batcher{size: size, interval: flushInterval}
+}
+
+// Handle 实现 Handler 接口，批未满时返回 None。
+func (b *Batcher) Handle(in interface{}) (interface{}, error) {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.batch) == 0 {
+		b.first = now
+	}
+	b.batch = append(b.batch, in)
+	if (b.size > 0 && len(b.batch) >= b.size) || b.expired(now) {
+		return b.take(), nil
+	}
+	return None, nil
+}
+
+// HandleMarker 实现 MarkerHandler 接口，源结束或窗口结束时输出剩余的数据，其他标记输出到期的批。
+func (b *Batcher) HandleMarker(m Marker) (interface{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.batch) == 0 {
+		return nil, nil
+	}
+	if m.Kind == MarkerEndOfSource || m.Kind == MarkerEndOfWindow || b.expired(m.Time) {
+		return b.take(), nil
+	}
+	return nil, nil
+}
+
+// expired 返回当前的批是否已经到期。调用方需持有 b.mu。
+func (b *Batcher) expired(now time.Time) bool {
+	return b.interval > 0 && len(b.batch) > 0 && now.Sub(b.first) >= b.interval
+}
+
+// take 取出当前的批。调用方需持有 b.mu。
+func (b *Batcher) take() []interface{} {
+	out := b.batch
+	b.batch = nil
+	return out
+}
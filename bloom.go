@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// BloomFilter 布隆过滤器，用于以很少的内存判断一个键是否出现过，可能误判为出现过，不会漏判。
+type BloomFilter struct {
+	m    uint64   // 位数
+	k    uint32   // 哈希函数个数
+	bits []uint64 // 位数组
+	n    uint64   // 添加过的键的个数（近似）
+}
+
+// NewBloomFilter 新建布隆过滤器，预计添加 expected 个键时误判率为 fpRate。
+func NewBloomFilter(expected uint64, fpRate float64) *BloomFilter {
+	if expected == 0 {
+		expected = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	m := uint64(math.Ceil(-float64(expected) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Round(float64(m) / float64(expected) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	m = (m + 63) / 64 * 64
+	return &BloomFilter{m: m, k: k, bits: make([]uint64, m/64)}
+}
+
+// bloomHash 返回键的两个 64 位哈希值，用于生成 k 个位置。
+func bloomHash(key string) (uint64, uint64) {
+	h := fnv.New128a()
+	io.WriteString(h, key)
+	var sum [16]byte
+	h.Sum(sum[:0])
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+// Add 添加键。
+func (bf *BloomFilter) Add(key string) {
+	bf.TestAndAdd(key)
+}
+
+// Test 判断键是否可能出现过。
+func (bf *BloomFilter) Test(key string) bool {
+	h1, h2 := bloomHash(key)
+	for i := uint32(0); i < bf.k; i++ {
+		pos := (h1 + uint64(i)*h2) % bf.m
+		if bf.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// TestAndAdd 添加键，返回添加前它是否可能出现过。
+func (bf *BloomFilter) TestAndAdd(key string) bool {
+	h1, h2 := bloomHash(key)
+	seen := true
+	for i := uint32(0); i < bf.k; i++ {
+		pos := (h1 + uint64(i)*h2) % bf.m
+		if bf.bits[pos/64]&(1<<(pos%64)) == 0 {
+			seen = false
+			bf.bits[pos/64] |= 1 << (pos % 64)
+		}
+	}
+	if !seen {
+		bf.n++
+	}
+	return seen
+}
+
+// Len 返回添加过的不同键的近似个数。
+func (bf *BloomFilter) Len() uint64 { return bf.n }
+
+// FalsePositiveRate 按当前添加的键数估算的误判率。
+func (bf *BloomFilter) FalsePositiveRate() float64 {
+	return math.Pow(1-math.Exp(-float64(bf.k)*float64(bf.n)/float64(bf.m)), float64(bf.k))
+}
+
+// Merge 把 o 合并到 bf 中，两个过滤器的参数必须相同。合并后的 Len 为两者之和，是一个上限。
+func (bf *BloomFilter) Merge(o *BloomFilter) error {
+	if bf.m != o.m || bf.k != o.k {
+		return fmt.Errorf("bloom filter: cannot merge m=%d k=%d into m=%d k=%d", o.m, o.k, bf.m, bf.k)
+	}
+	for i, w := range o.bits {
+		bf.bits[i] |= w
+	}
+	bf.n += o.n
+	return nil
+}
+
+const bloomMagic = "HBF1"
+
+// WriteTo 实现 io.WriterTo 接口。
+func (bf *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(bloomMagic)
+	binary.Write(bw, binary.LittleEndian, bf.m)
+	binary.Write(bw, binary.LittleEndian, bf.k)
+	binary.Write(bw, binary.LittleEndian, bf.n)
+	binary.Write(bw, binary.LittleEndian, bf.bits)
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	return int64(len(bloomMagic) + 8 + 4 + 8 + len(bf.bits)*8), nil
+}
+
+// ReadBloomFilter 读取 WriteTo 写入的布隆过滤器。
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(bloomMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != bloomMagic {
+		return nil, errors.New("bloom filter: bad magic")
+	}
+	bf := &BloomFilter{}
+	for _, v := range []interface{}{&bf.m, &bf.k, &bf.n} {
+		if err := binary.Read(br, binary.LittleEndian, v); err != nil {
+			return nil, err
+		}
+	}
+	if bf.m == 0 || bf.m%64 != 0 || bf.k == 0 || bf.m > 1<<40 {
+		return nil, errors.New("bloom filter: bad header")
+	}
+	// 头部中的 m 不可信，按块读取位数组，分配的内存不超过实际读到的数据的两倍。
+	words := bf.m / 64
+	for uint64(len(bf.bits)) < words {
+		n := words - uint64(len(bf.bits))
+		if n > bloomReadChunk {
+			n = bloomReadChunk
+		}
+		chunk := make([]uint64, n)
+		if err := binary.Read(br, binary.LittleEndian, chunk); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		bf.bits = append(bf.bits, chunk...)
+	}
+	return bf, nil
+}
+
+// bloomReadChunk ReadBloomFilter 每次读取的位数组长度（64 位字）。
+const bloomReadChunk = 1 << 16
+
+// SaveFile 保存到文件，先写临时文件再重命名。
+func (bf *BloomFilter) SaveFile(path string) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = bf.WriteTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadBloomFilter 读取 SaveFile 保存的文件。
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadBloomFilter(file)
+}
+
+// BloomDedup 基于布隆过滤器的跨运行去重处理器，丢弃键已经出现过的数据（包括以前的运行中出现的）。
+// 键按哈希值分到多个分区，每个分区一个过滤器，分别保存在目录中的 part-NNNN.bloom 文件里。
+// 由于布隆过滤器可能误判，少量未出现过的数据也会被丢弃，比例约为创建时指定的误判率。
+type BloomDedup struct {
+	key   func(in interface{}) string
+	dir   string
+	parts []*BloomFilter
+	mus   []sync.Mutex
+}
+
+// NewBloomDedup 新建去重处理器，dir 中已有的分区文件会被读取。
+// 每个分区预计 expected 个键，误判率为 fpRate；读取已有文件时以文件中的参数为准。
+func NewBloomDedup(key func(in interface{}) string, dir string, partitions int, expected uint64, fpRate float64) (*BloomDedup, error) {
+	if partitions <= 0 {
+		partitions = 1
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	bd := &BloomDedup{key: key, dir: dir, parts: make([]*BloomFilter, partitions), mus: make([]sync.Mutex, partitions)}
+	for i := range bd.parts {
+		bf, err := LoadBloomFilter(bd.partPath(dir, i))
+		if os.IsNotExist(err) {
+			bf, err = NewBloomFilter(expected, fpRate), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		bd.parts[i] = bf
+	}
+	return bd, nil
+}
+
+func (bd *BloomDedup) partPath(dir string, i int) string {
+	return filepath.Join(dir, fmt.Sprintf("part-%04d.bloom", i))
+}
+
+// Handle 实现 Handler 接口。
+func (bd *BloomDedup) Handle(in interface{}) (interface{}, error) {
+	k := bd.key(in)
+	// 分区使用单独的哈希，避免同一分区内的键在过滤器中的位置集中。
+	ph := fnv.New32()
+	io.WriteString(ph, k)
+	i := int(ph.Sum32() % uint32(len(bd.parts)))
+	bd.mus[i].Lock()
+	seen := bd.parts[i].TestAndAdd(k)
+	bd.mus[i].Unlock()
+	if seen {
+		return None, nil
+	}
+	return in, nil
+}
+
+// Save 保存所有分区，下次运行时继续去重。
+func (bd *BloomDedup) Save() error {
+	for i, bf := range bd.parts {
+		bd.mus[i].Lock()
+		err := bf.SaveFile(bd.partPath(bd.dir, i))
+		bd.mus[i].Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush 实现 Flusher 接口，Drain 时保存所有分区。
+func (bd *BloomDedup) Flush() error { return bd.Save() }
+
+// MergeDir 合并另一个 BloomDedup 保存的目录（例如并行运行的另一个任务），分区数和参数必须相同。
+func (bd *BloomDedup) MergeDir(dir string) error {
+	for i, bf := range bd.parts {
+		o, err := LoadBloomFilter(bd.partPath(dir, i))
+		if err != nil {
+			return err
+		}
+		bd.mus[i].Lock()
+		err = bf.Merge(o)
+		bd.mus[i].Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Builder 按 数据源 → 处理器 → 输出 的顺序组装 Handlers，例如
+//
+//	h, err := handlers.New().From(src).Then(h1).Then(h2).To(sink).Build()
+type Builder struct {
+	opts     []Option
+	srcs     []Source
+	handlers []Handler
+	sinks    []Sink
+}
+
+// New 新建 Builder，opts 在 Build 时传给 NewHandlers。
+func New(opts ...Option) *Builder {
+	return &Builder{opts: opts}
+}
+
+// From 添加数据源。
+func (b *Builder) From(srcs ...Source) *Builder {
+	b.srcs = append(b.srcs, srcs...)
+	return b
+}
+
+// Then 在处理链末尾添加处理器。
+func (b *Builder) Then(handlers ...Handler) *Builder {
+	b.handlers = append(b.handlers, handlers...)
+	return b
+}
+
+// ThenFunc 在处理链末尾添加处理器函数。
+func (b *Builder) ThenFunc(f HandlerFunc) *Builder {
+	return b.Then(f)
+}
+
+// To 添加输出。
+func (b *Builder) To(sinks ...Sink) *Builder {
+	b.sinks = append(b.sinks, sinks...)
+	return b
+}
+
+// Build 检查配置并返回可以直接 Run 的 Handlers：至少要有一个数据源，数据源、处理器和输出都不能为 nil。
+func (b *Builder) Build() (*Handlers, error) {
+	if len(b.srcs) == 0 {
+		return nil, errors.New("handlers: no source")
+	}
+	for i, src := range b.srcs {
+		if src == nil {
+			return nil, fmt.Errorf("handlers: source %d is nil", i)
+		}
+	}
+	for i, handler := range b.handlers {
+		if handler == nil {
+			return nil, fmt.Errorf("handlers: handler %d is nil", i)
+		}
+	}
+	for i, sink := range b.sinks {
+		if sink == nil {
+			return nil, fmt.Errorf("handlers: sink %d is nil", i)
+		}
+	}
+	h := NewHandlers(b.opts...)
+	for _, src := range b.srcs {
+		h.AddSrc(src)
+	}
+	for _, handler := range b.handlers {
+		h.AddHandler(handler)
+	}
+	h.AddSink(b.sinks...)
+	return h, nil
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Bulkhead 隔离的分支，用自己的队列和 goroutine 执行处理器，
+// 慢的或者出错的分支最多积压 buffer 条数据，不会拖慢主处理链和其他分支。
+// Handle 把数据放入队列后原样返回，因此可以直接放在处理链中作为旁路，也可以作为 SetSideOutput 的目标。
+// 分支中处理器的返回值被丢弃，错误交给 OnError。
+type Bulkhead struct {
+	h       Handler
+	queue   chan interface{}
+	workers sync.WaitGroup
+	pending sync.WaitGroup // 已入队但尚未处理完的数据
+	errors  uint64
+	dropped uint64
+
+	// BestEffort 为 true 时队列已满直接丢弃数据而不是阻塞，丢弃的数量由 Dropped 返回。
+	// 适用于指标、采样、归档等不重要的分支，需在第一次调用 Handle 前设置。
+	BestEffort bool
+	// OnError 分支中的处理器返回错误时调用，需在第一次调用 Handle 前设置。
+	OnError func(in interface{}, err error)
+}
+
+// NewBulkhead 新建隔离分支，workers 个 goroutine 并发调用 h，
+// workers > 1 时 h 必须是并发安全的。
+func NewBulkhead(h Handler, workers, buffer int) *Bulkhead {
+	if workers <= 0 {
+		workers = 1
+	}
+	if buffer < 0 {
+		buffer = 0
+	}
+	b := &Bulkhead{
+		h:     h,
+		queue: make(chan interface{}, buffer),
+	}
+	b.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go b.work()
+	}
+	return b
+}
+
+func (b *Bulkhead) work() {
+	defer b.workers.Done()
+	for in := range b.queue {
+		if _, err := callHandler(b.h, in); err != nil {
+			atomic.AddUint64(&b.errors, 1)
+			if b.OnError != nil {
+				b.OnError(in, err)
+			}
+		}
+		b.pending.Done()
+	}
+}
+
+// Handle 实现 Handler 接口，队列已满时阻塞，BestEffort 为 true 时丢弃。
+func (b *Bulkhead) Handle(in interface{}) (interface{}, error) {
+	b.pending.Add(1)
+	if !b.BestEffort {
+		b.queue <- in
+		return in, nil
+	}
+	select {
+	case b.queue <- in:
+	default:
+		b.pending.Done()
+		atomic.AddUint64(&b.dropped, 1)
+	}
+	return in, nil
+}
+
+// Dropped 返回 BestEffort 模式下因为队列已满被丢弃的数据条数。
+func (b *Bulkhead) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Errors 返回分支中处理失败的数据条数。
+func (b *Bulkhead) Errors() uint64 {
+	return atomic.LoadUint64(&b.errors)
+}
+
+// Flush 实现 Flusher 接口，等待队列中的数据处理完毕。
+func (b *Bulkhead) Flush() error {
+	b.pending.Wait()
+	if f, ok := b.h.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close 处理完队列中剩余的数据后停止 goroutine，之后不能再调用 Handle。
+func (b *Bulkhead) Close() error {
+	close(b.queue)
+	b.workers.Wait()
+	return nil
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Positioner 可选接口，数据源实现它以支持断点续传。
+type Positioner interface {
+	Source
+	// CheckpointKey 数据源的唯一标识，作为 CheckpointStore 中的键，多次运行之间应保持不变。
+	CheckpointKey() string
+	// Position 返回已经读取到的位置，即最近一次 Next 返回的数据之后。
+	Position() ([]byte, error)
+	// Restore 从 Position 返回的位置继续读取，在读取第一条数据之前调用。
+	Restore(pos []byte) error
+}
+
+// CheckpointStore 保存数据源的读取位置，实现必须是并发安全的。
+// 除了 FileCheckpoints，也可以基于 bolt、redis 等实现，或者通过 StoreCheckpoints 使用 Store。
+type CheckpointStore interface {
+	// Load 返回 key 保存的位置，没有时返回 nil, nil。
+	Load(key string) ([]byte, error)
+	// Save 保存 key 的位置。
+	Save(key string, pos []byte) error
+}
+
+// SetCheckpoint 启用断点续传：实现了 Positioner 的源开始处理前从 store 恢复上次保存的位置，
+// 处理过程中每隔 every 保存一次，读完时再保存一次；every <= 0 时只在读完时保存。
+// 保存前等待这个源在异步处理和流水线中的数据处理完毕，并调用 AddDurableSink 添加的输出的 Sync，
+// 保证保存的位置之前的数据都已经处理并写入。store 为 nil 时关闭断点续传。
+func (h *Handlers) SetCheckpoint(store CheckpointStore, every time.Duration) {
+	h.Lock()
+	h.ckpt = store
+	h.ckptEvery = every
+	h.Unlock()
+}
+
+// restoreSrc 从进度存储恢复源的位置。
+func (h *Handlers) restoreSrc(ent *srcEntry, opts *runOptions) error {
+	ent.saved = time.Now()
+	p, ok := ent.src.(Positioner)
+	if !ok || opts.ckpt == nil {
+		return nil
+	}
+	pos, err := opts.ckpt.Load(p.CheckpointKey())
+	if err != nil || pos == nil {
+		return err
+	}
+	if err := p.Restore(pos); err != nil {
+		return err
+	}
+	ent.restored = true
+	return nil
+}
+
+// checkpoint 距离上次保存超过 opts.ckptEvery 或 force 时保存源的位置。
+func (h *Handlers) checkpoint(ent *srcEntry, opts *runOptions, force bool) error {
+	p, ok := ent.src.(Positioner)
+	if !ok || opts.ckpt == nil {
+		return nil
+	}
+	if !force && (opts.ckptEvery <= 0 || time.Since(ent.saved) < opts.ckptEvery) {
+		return nil
+	}
+	// 只等待这个源的数据，其他源的 worker 仍在并发处理。
+	if err := ent.fl.wait(); err != nil {
+		return err
+	}
+	h.RLock()
+	durable := h.durable
+	h.RUnlock()
+	for _, s := range durable {
+		if err := s.Sync(); err != nil {
+			return err
+		}
+	}
+	pos, err := p.Position()
+	if err != nil {
+		return err
+	}
+	if err = opts.ckpt.Save(p.CheckpointKey(), pos); err != nil {
+		return err
+	}
+	ent.saved = time.Now()
+	return nil
+}
+
+// FileCheckpoints 把所有源的位置以 JSON 保存在一个文件中。
+type FileCheckpoints struct {
+	path string
+	mu   sync.Mutex
+	pos  map[string][]byte
+}
+
+// NewFileCheckpoints 新建保存在 path 中的进度存储，文件不存在时为空。
+func NewFileCheckpoints(path string) (*FileCheckpoints, error) {
+	fc := &FileCheckpoints{path: path, pos: make(map[string][]byte)}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &fc.pos); err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %v", path, err)
+	}
+	return fc, nil
+}
+
+// Load 实现 CheckpointStore 接口。
+func (fc *FileCheckpoints) Load(key string) ([]byte, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.pos[key], nil
+}
+
+// Save 实现 CheckpointStore 接口，先写临时文件再重命名。
+func (fc *FileCheckpoints) Save(key string, pos []byte) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.pos[key] = pos
+	b, err := json.MarshalIndent(fc.pos, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := fc.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fc.path)
+}
+
+// StoreCheckpoints 把位置保存在 Store 中，键为 Prefix + CheckpointKey。
+type StoreCheckpoints struct {
+	Store  Store
+	Prefix string
+}
+
+// Load 实现 CheckpointStore 接口。
+func (sc StoreCheckpoints) Load(key string) ([]byte, error) {
+	v, ok := sc.Store.Get(sc.Prefix + key)
+	if !ok {
+		return nil, nil
+	}
+	pos, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("checkpoint %s: unexpected value %T", key, v)
+	}
+	return pos, nil
+}
+
+// Save 实现 CheckpointStore 接口。
+func (sc StoreCheckpoints) Save(key string, pos []byte) error {
+	sc.Store.Set(sc.Prefix+key, pos, 0)
+	return nil
+}
+
+// CheckpointKey 实现 Positioner 接口，返回文件路径，Split 拆分出的分片加上分片的结束位置。
+func (fs *FileSource) CheckpointKey() string {
+	if fs.limit > 0 {
+		return fs.path + "@" + strconv.FormatInt(fs.limit, 10)
+	}
+	return fs.path
+}
+
+// Position 实现 Positioner 接口，返回已经读取到的偏移量，已知行号时加上 ":行号"。
+func (fs *FileSource) Position() ([]byte, error) {
+	pos := strconv.AppendInt(nil, fs.offset, 10)
+	if fs.lineBase >= 0 {
+		pos = strconv.AppendInt(append(pos, ':'), fs.lineBase+fs.line, 10)
+	}
+	return pos, nil
+}
+
+// Restore 实现 Positioner 接口，从偏移量处继续读取，行号接着保存时的行号。
+// 文件比偏移量小时说明被截断或替换，从头读取。
+func (fs *FileSource) Restore(pos []byte) error {
+	s, lineStr := string(pos), ""
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		s, lineStr = s[:i], s[i+1:]
+	}
+	offset, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("checkpoint %s: %v", fs.path, err)
+	}
+	line := int64(-1)
+	if lineStr != "" {
+		if line, err = strconv.ParseInt(lineStr, 10, 64); err != nil {
+			return fmt.Errorf("checkpoint %s: %v", fs.path, err)
+		}
+	}
+	if fs.file == nil {
+		return errors.New("file source closed")
+	}
+	if offset > fs.size {
+		offset, line = 0, 0
+	}
+	if offset == 0 {
+		line = 0
+	}
+	if _, err = fs.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	fs.r.Reset(fs.file)
+	fs.offset, fs.start, fs.line = offset, offset, 0
+	fs.lineFrom, fs.lineBase = offset, line
+	atomic.StoreInt64(&fs.read, offset)
+	return nil
+}
+
+// multiPosition MultiFileSrc 的位置。
+type multiPosition struct {
+	File   string `json:"file"`
+	Offset []byte `json:"offset"`
+}
+
+// CheckpointKey 实现 Positioner 接口，返回文件模式，没有文件模式时（例如 SplitSrc 的结果）返回所有文件路径。
+func (mfs *MultiFileSrc) CheckpointKey() string {
+	if mfs.pattern != "" {
+		return mfs.pattern
+	}
+	paths := make([]string, len(mfs.src))
+	for i, src := range mfs.src {
+		paths[i] = src.path
+	}
+	return strings.Join(paths, ",")
+}
+
+// Position 实现 Positioner 接口，返回正在读取的文件和其中的偏移量。
+func (mfs *MultiFileSrc) Position() ([]byte, error) {
+	var mp multiPosition
+	if src := mfs.current(); src != nil {
+		mp.File = src.path
+		mp.Offset, _ = src.Position()
+	}
+	return json.Marshal(mp)
+}
+
+// Restore 实现 Positioner 接口，跳过记录的文件之前的文件，从记录的偏移量继续读取。
+// 记录的文件已经不存在时从头读取。
+func (mfs *MultiFileSrc) Restore(pos []byte) error {
+	var mp multiPosition
+	if err := json.Unmarshal(pos, &mp); err != nil {
+		return fmt.Errorf("checkpoint %s: %v", mfs.pattern, err)
+	}
+	for i, src := range mfs.src {
+		if src.path != mp.File {
+			continue
+		}
+		if err := src.Restore(mp.Offset); err != nil {
+			return err
+		}
+		for _, done := range mfs.src[:i] {
+			done.Close()
+		}
+		mfs.index = i
+		atomic.StoreInt32(&mfs.cur, int32(i))
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointResumeLine(t *testing.T) {
+	dir := t.TempDir()
+	p := writeFile(t, dir, "a.txt", "1\n2\nbad3\n4\nbad5\n6\n")
+	store, err := NewFileCheckpoints(writeFile(t, dir, "ck.json", "{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, want := range []int64{3, 5, 0} {
+		src, err := NewFileSrc(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := NewHandlers(WithErrCheck(StopOnError), WithCheckpoint(store, 1))
+		h.AddSrc(src)
+		h.AddHandlerFunc(func(in interface{}) (interface{}, error) {
+			s := strings.TrimSpace(in.(string))
+			if strings.HasPrefix(s, "bad") {
+				return nil, errors.New(s)
+			}
+			got = append(got, s)
+			return in, nil
+		})
+		err = h.Run()
+		src.Close()
+		var se *SourceError
+		switch {
+		case want == 0 && err != nil:
+			t.Fatal(err)
+		case want > 0 && (!errors.As(err, &se) || se.Line != want || se.Err.Error() != "bad"+strconv.FormatInt(want, 10)):
+			t.Fatalf("want line %d, got %v", want, err)
+		}
+		// 跳过出错的行。
+		if want > 0 {
+			pos, _ := store.Load(p)
+			offset, _ := strconv.Atoi(strings.SplitN(string(pos), ":", 2)[0])
+			store.Save(p, []byte(strconv.Itoa(offset+len("badN\n"))+":"+strconv.FormatInt(want, 10)))
+		}
+	}
+	if strings.Join(got, ",") != "1,2,4,6" {
+		t.Fatal(got)
+	}
+}
+
+func TestSplitPartsLine(t *testing.T) {
+	dir := t.TempDir()
+	var b strings.Builder
+	for i := 1; i <= 100; i++ {
+		b.WriteString("line" + strconv.Itoa(i) + "\n")
+	}
+	fs, err := NewFileSrc(writeFile(t, dir, "a.txt", b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	var n int
+	for _, part := range fs.Split(4) {
+		part := part.(*FileSource)
+		for {
+			v, err := part.Next()
+			if err == io.EOF {
+				break
+			}
+			n++
+			if want := "line" + strconv.FormatInt(part.Line(), 10); strings.TrimSpace(v.(string)) != want {
+				t.Fatalf("%q at line %d", v, part.Line())
+			}
+		}
+		part.Close()
+	}
+	if n != 100 {
+		t.Fatal(n)
+	}
+}
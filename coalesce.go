@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type coalesced struct {
+	key   string
+	item  interface{}
+	first time.Time // 窗口内第一条数据到达的时间
+}
+
+// Coalescer 合并同一个键在窗口内连续到达的数据，窗口结束后只向后输出一条。
+// 适用于配置变更事件、抖动的传感器等。
+// 窗口从该键的第一条数据到达时开始，到期的数据在处理后续数据或收到控制标记时输出，
+// 源结束时输出所有尚未到期的数据。
+type Coalescer struct {
+	key     func(in interface{}) string
+	window  time.Duration
+	merge   func(old, in interface{}) interface{}
+	mu      sync.Mutex
+	pending map[string]*list.Element
+	order   *list.List // 按第一条数据到达的时间排序
+}
+
+// NewCoalescer 新建合并处理器，key 从数据中提取合并的键，
+// merge 合并同一个键的两条数据，为 nil 时保留最新的一条。
+func NewCoalescer(key func(in interface{}) string, window time.Duration, merge func(old, in interface{}) interface{}) *Coalescer {
+	return &Coalescer{
+		key:     key,
+		window:  window,
+		merge:   merge,
+		pending: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Handle 实现 Handler 接口，返回窗口已经到期的数据。
+func (c *Coalescer) Handle(in interface{}) (interface{}, error) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := c.expire(now, false)
+	k := c.key(in)
+	if ele, ok := c.pending[k]; ok {
+		p := ele.Value.(*coalesced)
+		if c.merge != nil {
+			p.item = c.merge(p.item, in)
+		} else {
+			p.item = in
+		}
+		return out, nil
+	}
+	c.pending[k] = c.order.PushBack(&coalesced{key: k, item: in, first: now})
+	return out, nil
+}
+
+// HandleMarker 实现 MarkerHandler 接口，源结束时输出所有数据，其他标记输出到期的数据。
+func (c *Coalescer) HandleMarker(m Marker) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.expire(m.Time, m.Kind == MarkerEndOfSource), nil
+}
+
+// expire 取出窗口已经到期的数据，all 为 true 时取出所有数据。调用方需持有 c.mu。
+func (c *Coalescer) expire(now time.Time, all bool) Emit {
+	out := Emit{}
+	for ele := c.order.Front(); ele != nil; ele = c.order.Front() {
+		p := ele.Value.(*coalesced)
+		if !all && now.Sub(p.first) < c.window {
+			break
+		}
+		c.order.Remove(ele)
+		delete(c.pending, p.key)
+		out = append(out, p.item)
+	}
+	return out
+}
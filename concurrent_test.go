@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncErrorStaysWithSource(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		h := &Handlers{}
+		h.SetConcurrency(2)
+		bad := make([]interface{}, 50)
+		good := make([]interface{}, 500)
+		for j := range bad {
+			bad[j] = "bad"
+		}
+		for j := range good {
+			good[j] = "good"
+		}
+		h.AddSrc(newSlice(bad...))
+		h.AddSrc(newSlice(good...))
+		h.AddAsyncHandler(AsyncHandlerFunc(func(in interface{}, done func(interface{}, error)) {
+			go func() {
+				if in == "bad" {
+					done(nil, errors.New("async boom"))
+					return
+				}
+				done(in, nil)
+			}()
+		}), 4)
+		var n int64
+		h.AddHandlerFunc(func(in interface{}) (interface{}, error) { atomic.AddInt64(&n, 1); return in, nil })
+		if err := h.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if n != 500 {
+			t.Fatalf("good source got %d items", n)
+		}
+	}
+}
+
+func TestChainEditDuringRunKeepsStats(t *testing.T) {
+	h := &Handlers{}
+	src := &chanSrc{c: make(chan interface{})}
+	h.AddSrc(src)
+	h.AddNamedHandler("a", HandlerFunc(func(in interface{}) (interface{}, error) {
+		if in == "bad" {
+			return nil, errors.New("bad")
+		}
+		return in, nil
+	}))
+	out := make(chan interface{}, 10)
+	h.AddHandlerFunc(func(in interface{}) (interface{}, error) { out <- in; return in, nil })
+	var errEvents int64
+	h.OnEvent(func(ev Event) {
+		if ev.Kind == EventHandlerError {
+			atomic.AddInt64(&errEvents, 1)
+		}
+	})
+	h.SetRejecter(nopRejecter{})
+	errc := make(chan error)
+	go func() { errc <- h.Run() }()
+	src.c <- "x"
+	<-out
+	if err := h.InsertAfter("a", HandlerFunc(func(in interface{}) (interface{}, error) { return in, nil })); err != nil {
+		t.Fatal(err)
+	}
+	src.c <- "y"
+	<-out
+	src.c <- "bad"
+	close(src.c)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	s := h.Stats()
+	if len(s.Handlers) != 3 || s.Handlers[0].Handled != 3 || s.Handlers[0].Errors != 1 || s.Handlers[1].Handled != 1 || s.Handlers[2].Handled != 2 {
+		t.Fatalf("%+v", s.Handlers)
+	}
+	if atomic.LoadInt64(&errEvents) != 1 {
+		t.Fatal(errEvents)
+	}
+}
+
+func TestNamedEditDuringSource(t *testing.T) {
+	h := &Handlers{}
+	src := &chanSrc{c: make(chan interface{})}
+	h.AddSrc(src)
+	h.AddNamedHandler("x", HandlerFunc(func(in interface{}) (interface{}, error) { return in.(int) * 10, nil }))
+	h.AddAsyncHandler(AsyncHandlerFunc(func(in interface{}, done func(interface{}, error)) {
+		go done(in, nil)
+	}), 4)
+	out := make(chan interface{}, 10)
+	h.AddHandlerFunc(func(in interface{}) (interface{}, error) { out <- in; return in, nil })
+	errc := make(chan error)
+	go func() { errc <- h.Run() }()
+	src.c <- 1
+	if v := <-out; v != 10 {
+		t.Fatal(v)
+	}
+	ok := make(chan error)
+	go func() {
+		ok <- h.ReplaceHandler("x", HandlerFunc(func(in interface{}) (interface{}, error) { return in.(int) * 100, nil }))
+	}()
+	select {
+	case err := <-ok:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ReplaceHandler blocked by running source")
+	}
+	src.c <- 2
+	if v := <-out; v != 200 {
+		t.Fatal(v)
+	}
+	close(src.c)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config 可以通过环境变量和命令行参数调整的配置。
+//
+// 优先级从低到高为：代码中设置的值、环境变量、命令行参数。
+// 先调用 LoadEnv，再调用 BindFlags 并解析命令行参数即可得到这样的优先级。
+type Config struct {
+	Workers       int           // HANDLERS_WORKERS, -workers
+	Quantum       int           // HANDLERS_QUANTUM, -quantum
+	Heartbeat     time.Duration // HANDLERS_HEARTBEAT, -heartbeat
+	HighWatermark int           // HANDLERS_HIGH_WATERMARK, -high-watermark
+	LowWatermark  int           // HANDLERS_LOW_WATERMARK, -low-watermark
+	Profile       string        // HANDLERS_PROFILE, -profile
+}
+
+// LoadEnv 用已设置的 HANDLERS_* 环境变量覆盖 c 中的值。
+func (c *Config) LoadEnv() error {
+	if err := envInt("HANDLERS_WORKERS", &c.Workers); err != nil {
+		return err
+	}
+	if err := envInt("HANDLERS_QUANTUM", &c.Quantum); err != nil {
+		return err
+	}
+	if v, ok := os.LookupEnv("HANDLERS_HEARTBEAT"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("handlers: invalid HANDLERS_HEARTBEAT: %v", err)
+		}
+		c.Heartbeat = d
+	}
+	if err := envInt("HANDLERS_HIGH_WATERMARK", &c.HighWatermark); err != nil {
+		return err
+	}
+	if err := envInt("HANDLERS_LOW_WATERMARK", &c.LowWatermark); err != nil {
+		return err
+	}
+	if v, ok := os.LookupEnv(ProfileEnv); ok {
+		c.Profile = v
+	}
+	return nil
+}
+
+func envInt(name string, dst *int) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("handlers: invalid %s: %v", name, err)
+	}
+	*dst = n
+	return nil
+}
+
+// BindFlags 在 fs 中注册对应的命令行参数，默认值为 c 中当前的值，解析后写回 c。
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.Workers, "workers", c.Workers, "sources processed concurrently")
+	fs.IntVar(&c.Quantum, "quantum", c.Quantum, "items handled per source before switching to the next")
+	fs.DurationVar(&c.Heartbeat, "heartbeat", c.Heartbeat, "interval of heartbeat markers, 0 disables")
+	fs.IntVar(&c.HighWatermark, "high-watermark", c.HighWatermark, "max items in flight, 0 disables")
+	fs.IntVar(&c.LowWatermark, "low-watermark", c.LowWatermark, "items in flight at which reading resumes")
+	fs.StringVar(&c.Profile, "profile", c.Profile, "handler profile to run")
+}
+
+// Apply 将配置应用到 h，下次 Run 时生效。
+func (h *Handlers) Apply(c Config) {
+	h.SetConcurrency(c.Workers)
+	h.SetQuantum(c.Quantum)
+	h.SetHeartbeat(c.Heartbeat)
+	h.SetWatermarks(c.HighWatermark, c.LowWatermark)
+	h.SetProfile(c.Profile)
+}
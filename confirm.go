@@ -0,0 +1,44 @@
+package handlers
+
+// SyncSink 能确认数据已经持久写入的输出，例如 fsync 文件、提交数据库事务、等待 Kafka 所有副本确认。
+type SyncSink interface {
+	Sink
+	// Sync 返回 nil 表示此前写入的所有数据都已经持久化。
+	Sync() error
+}
+
+// Committer 可选接口，数据源实现它以在数据确认写入后保存处理进度，例如 IncrementalFileSrc。
+type Committer interface {
+	Commit() error
+}
+
+// AddDurableSink 添加需要确认写入的输出，数据同时按 AddSink 的方式写入。
+// 添加了这类输出后，每个源处理完时先依次调用它们的 Sync，全部成功后才调用源的 Commit（源实现了 Committer 时），
+// 这样源的进度不会超过已经持久化的数据。Sync 或 Commit 失败时视为该源出错，交给 ErrCheck 处理。
+func (h *Handlers) AddDurableSink(sinks ...SyncSink) {
+	h.Lock()
+	for _, s := range sinks {
+		h.sinks = append(h.sinks, s)
+		h.durable = append(h.durable, s)
+	}
+	h.Unlock()
+}
+
+// confirmSrc 源处理完时确认输出已经持久化，然后提交源的进度。没有需要确认的输出时什么都不做。
+func (h *Handlers) confirmSrc(src Source) error {
+	h.RLock()
+	durable := h.durable
+	h.RUnlock()
+	if len(durable) == 0 {
+		return nil
+	}
+	for _, s := range durable {
+		if err := s.Sync(); err != nil {
+			return err
+		}
+	}
+	if c, ok := src.(Committer); ok {
+		return c.Commit()
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// ContractError 数据不符合合约。
+type ContractError struct {
+	Contract   string   // 合约名称
+	Violations []string // 不符合的地方
+}
+
+func (e *ContractError) Error() string {
+	return fmt.Sprintf("contract %q violated: %s", e.Contract, strings.Join(e.Violations, "; "))
+}
+
+// ContractReport 合约的检查结果。
+type ContractReport struct {
+	Name       string `json:"name"`
+	Checked    int64  `json:"checked"`    // 检查的数据条数
+	Violations int64  `json:"violations"` // 不符合合约的数据条数
+}
+
+// Contract 数据合约，放在输出之前检查数据是否符合约定的 Schema，防止结构变化悄悄进入下游。
+// 不符合的数据返回 *ContractError，配合 SetRejecter 可以把它们连同原因写入拒绝文件而不中止数据源。
+// 检查结果会汇总到 RunSummary.Contracts。
+type Contract struct {
+	name       string
+	schema     Schema
+	checked    int64
+	violations int64
+}
+
+// NewContract 新建数据合约。
+func NewContract(name string, schema Schema) *Contract {
+	return &Contract{name: name, schema: schema}
+}
+
+// String 返回合约名称。
+func (c *Contract) String() string { return "Contract(" + c.name + ")" }
+
+// Handle 实现 Handler 接口。
+func (c *Contract) Handle(in interface{}) (interface{}, error) {
+	atomic.AddInt64(&c.checked, 1)
+	if v := c.schema.Check(in); len(v) > 0 {
+		atomic.AddInt64(&c.violations, 1)
+		return nil, &ContractError{Contract: c.name, Violations: v}
+	}
+	return in, nil
+}
+
+// Report 返回累计的检查结果。
+func (c *Contract) Report() ContractReport {
+	return ContractReport{
+		Name:       c.name,
+		Checked:    atomic.LoadInt64(&c.checked),
+		Violations: atomic.LoadInt64(&c.violations),
+	}
+}
+
+// contractReports 返回处理链中所有数据合约的检查结果。
+func (h *Handlers) contractReports() []ContractReport {
+	var reports []ContractReport
+	h.eachHandler(func(v Handler) {
+		if c, ok := v.(*Contract); ok {
+			reports = append(reports, c.Report())
+		}
+	})
+	return reports
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Converter 转换一个字段的值。
+type Converter func(v interface{}) (interface{}, error)
+
+// FieldConverter 按字段转换 map[string]interface{} 数据，字段名为按 . 分隔的路径。
+// 字段不存在或为 nil 时跳过，不修改原数据。
+type FieldConverter struct {
+	fields []string
+	paths  [][]string
+	convs  []Converter
+}
+
+// NewFieldConverter 新建按字段转换的处理器，converters 以字段名为键。
+func NewFieldConverter(converters map[string]Converter) *FieldConverter {
+	fc := &FieldConverter{}
+	for f := range converters {
+		fc.fields = append(fc.fields, f)
+	}
+	sort.Strings(fc.fields)
+	for _, f := range fc.fields {
+		fc.paths = append(fc.paths, strings.Split(f, "."))
+		fc.convs = append(fc.convs, converters[f])
+	}
+	return fc
+}
+
+// Handle 实现 Handler 接口。
+func (fc *FieldConverter) Handle(in interface{}) (interface{}, error) {
+	m, ok := in.(map[string]interface{})
+	if !ok {
+		return in, nil
+	}
+	for i, path := range fc.paths {
+		v, ok := fieldValue(m, fc.fields[i])
+		if !ok || v == nil {
+			continue
+		}
+		out, err := fc.convs[i](v)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %v", fc.fields[i], err)
+		}
+		m = setField(m, path, out)
+	}
+	return m, nil
+}
+
+// setField 返回把 path 字段设置为 v 的 m 的副本，path 上经过的 map 也会复制，不存在时创建。
+func setField(m map[string]interface{}, path []string, v interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m)+1)
+	for k, old := range m {
+		out[k] = old
+	}
+	if len(path) == 1 {
+		out[path[0]] = v
+		return out
+	}
+	sub, _ := m[path[0]].(map[string]interface{})
+	out[path[0]] = setField(sub, path[1:], v)
+	return out
+}
+
+// cleanNumber 去掉数字中的空白、千分位分隔符，并把小数点统一为 '.'。
+// decimalSep 为小数点，例如 '.'（1,234.5）或 ','（1.234,5）；另一个符号视为千分位分隔符。
+func cleanNumber(s string, decimalSep rune) (string, error) {
+	group := ','
+	if decimalSep == ',' {
+		group = '.'
+	}
+	var b strings.Builder
+	seenDecimal := false
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case r == decimalSep:
+			if seenDecimal {
+				return "", fmt.Errorf("invalid number %q", s)
+			}
+			seenDecimal = true
+			b.WriteByte('.')
+		case r == group || r == '_' || r == '\'' || unicode.IsSpace(r):
+			if seenDecimal {
+				return "", fmt.Errorf("invalid number %q", s)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("invalid number %q", s)
+	}
+	return b.String(), nil
+}
+
+// ParseNumber 解析带千分位分隔符的数字，decimalSep 为小数点，例如 "1.234,5" 使用 ','。
+func ParseNumber(s string, decimalSep rune) (float64, error) {
+	c, err := cleanNumber(s, decimalSep)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(c, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return f, nil
+}
+
+// ParseDecimal 和 ParseNumber 相同，但返回精确的 *big.Rat，适用于金额等不能有浮点误差的数据。
+// 和 ParseNumber 一样不接受分数形式（例如 "1/3"）。
+func ParseDecimal(s string, decimalSep rune) (*big.Rat, error) {
+	c, err := cleanNumber(s, decimalSep)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ContainsRune(c, '/') {
+		return nil, fmt.Errorf("invalid decimal %q", s)
+	}
+	r, ok := new(big.Rat).SetString(c)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %q", s)
+	}
+	return r, nil
+}
+
+// currencySymbols 货币符号对应的 ISO 4217 代码，按符号长度从长到短排列，
+// 使 "HK$" 等先于 "$" 匹配。
+var currencySymbols = []struct{ sym, code string }{
+	{"US$", "USD"}, {"HK$", "HKD"}, {"NT$", "TWD"}, {"A$", "AUD"}, {"C$", "CAD"}, {"R$", "BRL"},
+	{"$", "USD"}, {"€", "EUR"}, {"£", "GBP"}, {"¥", "JPY"}, {"₹", "INR"}, {"₩", "KRW"}, {"₽", "RUB"}, {"元", "CNY"},
+}
+
+// ParseMoney 解析金额，例如 "$1,234.50"、"12,30 €"、"USD 12.00"、"-¥500"。
+// 返回精确的金额和 ISO 4217 货币代码，没有货币符号时代码为空。
+func ParseMoney(s string, decimalSep rune) (amount *big.Rat, currency string, err error) {
+	t := strings.TrimSpace(s)
+	neg := false
+	if strings.HasPrefix(t, "-") {
+		neg, t = true, strings.TrimSpace(t[1:])
+	}
+	for _, cs := range currencySymbols {
+		if strings.HasPrefix(t, cs.sym) {
+			t, currency = t[len(cs.sym):], cs.code
+			break
+		}
+		if strings.HasSuffix(t, cs.sym) {
+			t, currency = t[:len(t)-len(cs.sym)], cs.code
+			break
+		}
+	}
+	if currency == "" {
+		// ISO 代码前缀或后缀，例如 "USD 12.00"、"12.00 EUR"。
+		if f := strings.Fields(t); len(f) == 2 {
+			if isCurrencyCode(f[0]) {
+				t, currency = f[1], f[0]
+			} else if isCurrencyCode(f[1]) {
+				t, currency = f[0], f[1]
+			}
+		}
+	}
+	t = strings.TrimSpace(t)
+	if strings.HasPrefix(t, "-") {
+		neg, t = !neg, t[1:]
+	}
+	amount, err = ParseDecimal(t, decimalSep)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid money %q", s)
+	}
+	if neg {
+		amount.Neg(amount)
+	}
+	return amount, currency, nil
+}
+
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// 字节单位，kB、MB 等为 1000 进制，KiB、MiB 等为 1024 进制，K、M 等单字母按 1024 进制。
+var byteUnits = map[string]float64{
+	"": 1, "b": 1,
+	"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12, "pb": 1e15,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40, "pib": 1 << 50,
+	"k": 1 << 10, "m": 1 << 20, "g": 1 << 30, "t": 1 << 40, "p": 1 << 50,
+}
+
+// ParseBytes 解析带单位的大小，例如 "512"、"1.5KB"、"10 MiB"、"2G"、"1e3"，返回字节数。
+// 数字部分和 ParseNumber 一样可以使用指数形式。
+func ParseBytes(s string) (int64, error) {
+	t := strings.TrimSpace(s)
+	// 单位是末尾连续的字母，指数中的 e 后面跟着数字，不会被当作单位。
+	i := strings.LastIndexFunc(t, func(r rune) bool { return !unicode.IsLetter(r) }) + 1
+	num, unit := strings.TrimSpace(t[:i]), strings.ToLower(t[i:])
+	mul, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q: unknown unit", s)
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(f * mul), nil
+}
+
+// ParseDurationUnit 解析时长，没有单位的数字按 unit 计算，例如 unit 为 time.Millisecond 时 "250" 为 250ms。
+// 除 time.ParseDuration 支持的单位外还支持 d（天）。
+func ParseDurationUnit(s string, unit time.Duration) (time.Duration, error) {
+	t := strings.TrimSpace(s)
+	if f, err := strconv.ParseFloat(t, 64); err == nil {
+		return time.Duration(f * float64(unit)), nil
+	}
+	if strings.HasSuffix(t, "d") {
+		if f, err := strconv.ParseFloat(strings.TrimSuffix(t, "d"), 64); err == nil {
+			return time.Duration(f * float64(24*time.Hour)), nil
+		}
+	}
+	return time.ParseDuration(t)
+}
+
+// ToNumber 返回把字符串字段解析为 float64 的 Converter，数字类型的值统一为 float64。
+func ToNumber(decimalSep rune) Converter {
+	return func(v interface{}) (interface{}, error) {
+		if s, ok := v.(string); ok {
+			return ParseNumber(s, decimalSep)
+		}
+		if f, ok := normalizeValue(v).(float64); ok {
+			return f, nil
+		}
+		return nil, fmt.Errorf("cannot convert %T to number", v)
+	}
+}
+
+// ToDecimal 返回把字符串字段解析为 *big.Rat 的 Converter。
+func ToDecimal(decimalSep rune) Converter {
+	return func(v interface{}) (interface{}, error) {
+		switch x := v.(type) {
+		case *big.Rat:
+			return x, nil
+		case string:
+			return ParseDecimal(x, decimalSep)
+		}
+		if f, ok := normalizeValue(v).(float64); ok {
+			return new(big.Rat).SetFloat64(f), nil
+		}
+		return nil, fmt.Errorf("cannot convert %T to decimal", v)
+	}
+}
+
+// ToBytes 返回把大小字段（例如 "10MB"）转换为字节数的 Converter。
+func ToBytes() Converter {
+	return func(v interface{}) (interface{}, error) {
+		if s, ok := v.(string); ok {
+			return ParseBytes(s)
+		}
+		if f, ok := normalizeValue(v).(float64); ok {
+			return int64(f), nil
+		}
+		return nil, fmt.Errorf("cannot convert %T to bytes", v)
+	}
+}
+
+// ToDuration 返回把时长字段转换为 time.Duration 的 Converter，没有单位的数字按 unit 计算。
+func ToDuration(unit time.Duration) Converter {
+	return func(v interface{}) (interface{}, error) {
+		switch x := v.(type) {
+		case time.Duration:
+			return x, nil
+		case string:
+			return ParseDurationUnit(x, unit)
+		}
+		if f, ok := normalizeValue(v).(float64); ok {
+			return time.Duration(f * float64(unit)), nil
+		}
+		return nil, fmt.Errorf("cannot convert %T to duration", v)
+	}
+}
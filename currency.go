@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateProvider 提供 at 时刻 1 单位 from 货币兑换 to 货币的汇率。实现必须是并发安全的。
+type RateProvider interface {
+	Rate(from, to string, at time.Time) (float64, error)
+}
+
+type ratePoint struct {
+	date time.Time
+	rate float64
+}
+
+// RateTable 按日期的汇率表，汇率为 1 单位货币兑换基准货币的数量。
+// 查询某个时刻的汇率时使用该时刻之前（含）最近的一条，早于所有记录时返回错误。
+type RateTable struct {
+	base  string
+	mu    sync.RWMutex
+	rates map[string][]ratePoint // 按日期排序
+}
+
+// NewRateTable 新建以 base 为基准货币的汇率表。
+func NewRateTable(base string) *RateTable {
+	return &RateTable{base: strings.ToUpper(base), rates: make(map[string][]ratePoint)}
+}
+
+// Set 设置从 date 开始 1 单位 currency 兑换基准货币的汇率。
+func (rt *RateTable) Set(currency string, date time.Time, rate float64) {
+	currency = strings.ToUpper(currency)
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	pts := rt.rates[currency]
+	i := sort.Search(len(pts), func(i int) bool { return !pts[i].date.Before(date) })
+	if i < len(pts) && pts[i].date.Equal(date) {
+		pts[i].rate = rate
+		return
+	}
+	pts = append(pts, ratePoint{})
+	copy(pts[i+1:], pts[i:])
+	pts[i] = ratePoint{date: date, rate: rate}
+	rt.rates[currency] = pts
+}
+
+// toBase 返回 at 时刻 1 单位 currency 兑换基准货币的汇率。
+func (rt *RateTable) toBase(currency string, at time.Time) (float64, error) {
+	if currency == rt.base {
+		return 1, nil
+	}
+	rt.mu.RLock()
+	pts := rt.rates[currency]
+	rt.mu.RUnlock()
+	i := sort.Search(len(pts), func(i int) bool { return pts[i].date.After(at) })
+	if i == 0 {
+		return 0, fmt.Errorf("rates: no %s rate at %s", currency, at.Format("2006-01-02"))
+	}
+	return pts[i-1].rate, nil
+}
+
+// Rate 实现 RateProvider 接口，非基准货币之间通过基准货币换算。
+func (rt *RateTable) Rate(from, to string, at time.Time) (float64, error) {
+	f, err := rt.toBase(strings.ToUpper(from), at)
+	if err != nil {
+		return 0, err
+	}
+	t, err := rt.toBase(strings.ToUpper(to), at)
+	if err != nil {
+		return 0, err
+	}
+	return f / t, nil
+}
+
+// ReadRates 从 CSV 读取汇率表，每行为 date,currency,rate，date 的格式为 2006-01-02，
+// 第一行不是日期时视为表头跳过。
+func ReadRates(r io.Reader, base string) (*RateTable, error) {
+	rt := NewRateTable(base)
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 3
+	cr.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return rt, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		date, err := time.Parse("2006-01-02", rec[0])
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("rates: line %d: %v", line, err)
+		}
+		rate, err := strconv.ParseFloat(rec[2], 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("rates: line %d: invalid rate %q", line, rec[2])
+		}
+		rt.Set(rec[1], date, rate)
+	}
+}
+
+// LoadRates 从 CSV 文件读取汇率表，格式见 ReadRates。
+func LoadRates(path, base string) (*RateTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadRates(f, base)
+}
+
+// CachedRates 缓存 load 加载的汇率表，超过 ttl 后在下次查询时重新加载，
+// 适用于从文件或接口定期更新的汇率。重新加载失败时继续使用旧的汇率表。
+type CachedRates struct {
+	load   func() (*RateTable, error)
+	ttl    time.Duration
+	mu     sync.Mutex
+	table  *RateTable
+	loaded time.Time
+}
+
+// NewCachedRates 新建缓存的汇率，ttl <= 0 表示只加载一次。
+func NewCachedRates(load func() (*RateTable, error), ttl time.Duration) *CachedRates {
+	return &CachedRates{load: load, ttl: ttl}
+}
+
+// current 返回当前的汇率表，需要时重新加载。
+func (cr *CachedRates) current() (*RateTable, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if cr.table != nil && (cr.ttl <= 0 || time.Since(cr.loaded) < cr.ttl) {
+		return cr.table, nil
+	}
+	t, err := cr.load()
+	if err != nil {
+		if cr.table != nil {
+			return cr.table, nil
+		}
+		return nil, err
+	}
+	cr.table, cr.loaded = t, time.Now()
+	return t, nil
+}
+
+// Rate 实现 RateProvider 接口。
+func (cr *CachedRates) Rate(from, to string, at time.Time) (float64, error) {
+	t, err := cr.current()
+	if err != nil {
+		return 0, err
+	}
+	return t.Rate(from, to, at)
+}
+
+// CurrencyConverter 把 map[string]interface{} 数据中的金额换算为目标货币，不会修改原数据。
+// 汇率按事件时间（TimeField）查询，没有设置 TimeField 或数据中没有该字段时使用处理时间。
+// 金额为 *big.Rat 时结果也是 *big.Rat，否则为 float64。
+type CurrencyConverter struct {
+	rates  RateProvider
+	target string
+
+	AmountField   string
+	CurrencyField string
+	TimeField     string // 时间字段，可以是 time.Time、RFC 3339 或 2006-01-02 格式的字符串、Unix 秒数
+	// OutputField 结果写入的字段，为空时覆盖 AmountField 并把 CurrencyField 改为目标货币。
+	OutputField string
+}
+
+// NewCurrencyConverter 新建货币换算处理器。
+func NewCurrencyConverter(rates RateProvider, target, amountField, currencyField, timeField string) *CurrencyConverter {
+	return &CurrencyConverter{rates: rates, target: strings.ToUpper(target),
+		AmountField: amountField, CurrencyField: currencyField, TimeField: timeField}
+}
+
+// Handle 实现 Handler 接口，没有金额的数据原样返回。
+func (cc *CurrencyConverter) Handle(in interface{}) (interface{}, error) {
+	m, ok := in.(map[string]interface{})
+	if !ok {
+		return in, nil
+	}
+	amount, ok := fieldValue(m, cc.AmountField)
+	if !ok || amount == nil {
+		return in, nil
+	}
+	cur, _ := fieldValue(m, cc.CurrencyField)
+	from, _ := cur.(string)
+	if from == "" {
+		return nil, fmt.Errorf("currency converter: missing currency field %q", cc.CurrencyField)
+	}
+	at, err := cc.eventTime(m)
+	if err != nil {
+		return nil, fmt.Errorf("currency converter: %v", err)
+	}
+	rate, err := cc.rates.Rate(from, cc.target, at)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	switch v := amount.(type) {
+	case *big.Rat:
+		r := new(big.Rat).SetFloat64(rate)
+		out = r.Mul(r, v)
+	case string:
+		f, err := ParseNumber(v, '.')
+		if err != nil {
+			return nil, fmt.Errorf("currency converter: %v", err)
+		}
+		out = f * rate
+	default:
+		f, ok := normalizeValue(v).(float64)
+		if !ok {
+			return nil, fmt.Errorf("currency converter: %T is not an amount", v)
+		}
+		out = f * rate
+	}
+	if cc.OutputField != "" {
+		return setField(m, strings.Split(cc.OutputField, "."), out), nil
+	}
+	m = setField(m, strings.Split(cc.AmountField, "."), out)
+	return setField(m, strings.Split(cc.CurrencyField, "."), cc.target), nil
+}
+
+// eventTime 返回数据的事件时间。
+func (cc *CurrencyConverter) eventTime(m map[string]interface{}) (time.Time, error) {
+	if cc.TimeField == "" {
+		return time.Now(), nil
+	}
+	v, ok := fieldValue(m, cc.TimeField)
+	if !ok || v == nil {
+		return time.Now(), nil
+	}
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		if ts, err := time.Parse(time.RFC3339, t); err == nil {
+			return ts, nil
+		}
+		return time.Parse("2006-01-02", t)
+	}
+	if f, ok := normalizeValue(v).(float64); ok {
+		return time.Unix(int64(f), 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %v", v)
+}
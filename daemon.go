@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// SetDaemon 设置守护模式，在下次 Run 时生效。守护模式下待处理队列为空时 Run 不会返回，
+// 而是等待 AddSrc 添加新的数据源，直到调用 Stop 或 Drain，或 RunContext 的 ctx 结束，
+// 这样 Handlers 可以作为持续接收任务的队列使用。
+func (h *Handlers) SetDaemon(on bool) {
+	h.Lock()
+	h.daemon = on
+	h.Unlock()
+}
+
+// wakeSrc 唤醒等待新数据源的 Run。
+func (h *Handlers) wakeSrc() {
+	h.Lock()
+	if h.srcWake != nil {
+		close(h.srcWake)
+		h.srcWake = nil
+	}
+	h.Unlock()
+}
+
+// waitSrc 等待新的数据源，有新数据源时返回 true；Drain、Stop 或 ctx 结束时返回 false。
+func (h *Handlers) waitSrc(ctx context.Context) bool {
+	h.Lock()
+	if h.srcWake == nil {
+		h.srcWake = make(chan struct{})
+	}
+	ch := h.srcWake
+	h.Unlock()
+	// 取得 ch 之后再检查，避免错过在此之前添加的数据源。
+	if atomic.LoadInt32(&h.draining) == 1 || ctx.Err() != nil {
+		return false
+	}
+	if listLen(h.todoSrc) > 0 {
+		return true
+	}
+	select {
+	case <-ch:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
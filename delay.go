@@ -0,0 +1,30 @@
+package handlers
+
+import "time"
+
+// Delay 延迟处理器，数据到达后等待一段时间再交给后面的处理器。
+// 它是一个 AsyncHandler，应通过 AddAsyncHandler 添加，等待期间不会阻塞后续数据，
+// 同时等待的数据条数受 maxOutstanding 限制。
+type Delay struct {
+	delay func(in interface{}) time.Duration
+}
+
+// NewDelay 新建固定延迟的处理器。
+func NewDelay(d time.Duration) *Delay {
+	return &Delay{delay: func(interface{}) time.Duration { return d }}
+}
+
+// NewDelayFunc 新建按数据计算延迟的处理器，例如根据数据中的时间戳计算到期时间。
+func NewDelayFunc(delay func(in interface{}) time.Duration) *Delay {
+	return &Delay{delay: delay}
+}
+
+// HandleAsync 实现 AsyncHandler 接口。
+func (d *Delay) HandleAsync(in interface{}, done func(out interface{}, err error)) {
+	wait := d.delay(in)
+	if wait <= 0 {
+		done(in, nil)
+		return
+	}
+	time.AfterFunc(wait, func() { done(in, nil) })
+}
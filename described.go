@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DescribedSource 可选接口，数据源实现它以报告自身的信息，用于进度显示、调度和错误信息。
+type DescribedSource interface {
+	Source
+	// Name 返回数据源的名称，例如文件名。
+	Name() string
+	// Size 返回数据的总字节数，未知时返回 -1。
+	Size() int64
+	// EstimatedItems 返回估计的数据总条数，未知时返回 -1。
+	EstimatedItems() int64
+}
+
+// lineSource 可以报告当前行号的数据源。
+type lineSource interface {
+	Line() int64
+}
+
+// SourceError 处理器处理数据时返回的错误，附带数据源的名称和出错的行号。
+// 只有实现了 DescribedSource 的数据源才会附带这些信息。
+type SourceError struct {
+	Source string // 数据源的名称
+	Line   int64  // 出错的行号，0 表示未知
+	Err    error  // 处理器返回的原始错误
+}
+
+// Error 实现 error 接口。
+func (se *SourceError) Error() string {
+	if se.Line > 0 {
+		return fmt.Sprintf("%s at line %d: %v", se.Source, se.Line, se.Err)
+	}
+	return fmt.Sprintf("%s: %v", se.Source, se.Err)
+}
+
+// Unwrap 返回原始错误，支持 errors.Is 和 errors.As。
+func (se *SourceError) Unwrap() error {
+	return se.Err
+}
+
+// wrapSrcErr 给处理器返回的错误附带数据源的信息。
+func wrapSrcErr(src Source, err error) error {
+	ds, ok := src.(DescribedSource)
+	if !ok {
+		return err
+	}
+	if _, ok = err.(*SourceError); ok {
+		return err
+	}
+	se := &SourceError{Source: ds.Name(), Err: err}
+	if ls, ok := src.(lineSource); ok {
+		se.Line = ls.Line()
+	}
+	return se
+}
+
+// Name 实现 DescribedSource 接口，返回文件路径。
+func (fs *FileSource) Name() string {
+	return fs.path
+}
+
+// Size 实现 DescribedSource 接口，返回需要读取的字节数。
+func (fs *FileSource) Size() int64 {
+	end := fs.size
+	if fs.limit > 0 && fs.limit < end {
+		end = fs.limit
+	}
+	return end - fs.start
+}
+
+// EstimatedItems 实现 DescribedSource 接口，根据已经读取的行的平均长度估计总行数。
+func (fs *FileSource) EstimatedItems() int64 {
+	read := fs.offset - fs.start
+	if fs.line == 0 || read == 0 {
+		return -1
+	}
+	return fs.Size() * fs.line / read
+}
+
+// Line 返回最近一次 Next 返回的行在文件中的行号，从文件开头计数。
+// 从文件中间开始读取时（Split 的分片、从进度恢复、增量读取），第一次调用时统计之前的行数。
+func (fs *FileSource) Line() int64 {
+	if fs.lineBase < 0 {
+		n, err := countLines(fs.path, fs.lineFrom)
+		if err != nil {
+			return 0 // 未知
+		}
+		fs.lineBase = n
+	}
+	return fs.lineBase + fs.line
+}
+
+// countLines 返回文件 path 的前 n 个字节中的换行符个数。
+func countLines(path string, n int64) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	var lines int64
+	buf := make([]byte, 32*1024)
+	r := io.LimitReader(file, n)
+	for {
+		m, err := r.Read(buf)
+		lines += int64(bytes.Count(buf[:m], []byte{'\n'}))
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Name 实现 DescribedSource 接口，返回正在读取的文件路径，所有文件都读完时返回文件模式。
+func (mfs *MultiFileSrc) Name() string {
+	if src := mfs.current(); src != nil {
+		return src.Name()
+	}
+	return mfs.pattern
+}
+
+// Size 实现 DescribedSource 接口，返回所有文件的总字节数。
+func (mfs *MultiFileSrc) Size() int64 {
+	var size int64
+	for _, src := range mfs.src {
+		size += src.Size()
+	}
+	return size
+}
+
+// EstimatedItems 实现 DescribedSource 接口，返回所有文件估计行数之和，有文件无法估计时返回 -1。
+func (mfs *MultiFileSrc) EstimatedItems() int64 {
+	var n int64
+	for _, src := range mfs.src {
+		e := src.EstimatedItems()
+		if e < 0 {
+			return -1
+		}
+		n += e
+	}
+	return n
+}
+
+// Line 返回正在读取的文件中最近一次 Next 返回的行的行号。
+func (mfs *MultiFileSrc) Line() int64 {
+	if src := mfs.current(); src != nil {
+		return src.Line()
+	}
+	return 0
+}
+
+// current 返回最近一次 Next 读取的文件。Next 读完一个文件后 index 会指向下一个文件，
+// 此时返回刚读完的文件，以便报告最后一行的位置。
+func (mfs *MultiFileSrc) current() *FileSource {
+	i := mfs.index
+	if i >= len(mfs.src) || i > 0 && mfs.src[i].line == 0 {
+		i--
+	}
+	if i < 0 || i >= len(mfs.src) {
+		return nil
+	}
+	return mfs.src[i]
+}
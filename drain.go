@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// ErrDrainTimeout Drain 等待超时。
+var ErrDrainTimeout = errors.New("handlers drain timeout")
+
+// errDraining 内部使用，表示因为 Drain 停止了拉取数据。
+var errDraining = errors.New("handlers draining")
+
+// Flusher 可选接口，处理器实现它以在 Drain 时输出缓存的数据。
+type Flusher interface {
+	Flush() error
+}
+
+// Drain 停止从数据源拉取新数据，等待正在处理的数据处理完毕，刷新所有实现了 Flusher 的处理器和输出。
+// 正在运行时由 Run 在结束前刷新，刷新的错误由 Run 返回；没有运行时由 Drain 刷新。
+// 未处理完的源会放回待处理队列的队首。
+// timeout <= 0 表示一直等待；超时返回 ErrDrainTimeout 以及仍在处理中（被放弃）的数据条数。
+func (h *Handlers) Drain(timeout time.Duration) (abandoned int, err error) {
+	h.Lock()
+	done := h.done
+	running := isActive(h.state)
+	oldState := h.state
+	if running {
+		h.state = StatusDraining
+		h.resumeLocked()
+	}
+	onChange := h.OnStateChange
+	h.Unlock()
+	if onChange != nil && running && oldState != StatusDraining {
+		onChange(oldState, StatusDraining)
+	}
+
+	if running && done != nil {
+		atomic.StoreInt32(&h.draining, 1)
+		h.wakeSrc()
+		var timer <-chan time.Time
+		if timeout > 0 {
+			t := time.NewTimer(timeout)
+			defer t.Stop()
+			timer = t.C
+		}
+		select {
+		case <-done:
+		case <-timer:
+			return h.InFlight(), ErrDrainTimeout
+		}
+		return 0, nil
+	}
+	return 0, h.flush()
+}
+
+// ErrStopped Run 因为 Stop 而结束。
+var ErrStopped = errors.New("handlers stopped")
+
+// Stop 停止 Run：不再拉取新数据，等待正在处理的数据处理完毕，刷新所有实现了 Flusher 的处理器和输出
+// （和 Drain 一样，正在运行时由 Run 刷新），然后关闭所有未处理完的源（实现了 io.Closer 的）。
+// Run 返回 ErrStopped，状态变为 StatusStop。
+// 未处理完的源仍留在待处理队列中，但已经关闭，不能再处理。
+func (h *Handlers) Stop() error {
+	h.Lock()
+	done := h.done
+	running := isActive(h.state)
+	atomic.StoreInt32(&h.stopping, 1)
+	atomic.StoreInt32(&h.draining, 1)
+	h.resumeLocked()
+	h.Unlock()
+	h.wakeSrc()
+	errBuf := bytes.Buffer{}
+	if running && done != nil {
+		<-done
+	} else {
+		h.setState(StatusStop)
+		if err := h.flush(); err != nil {
+			errBuf.WriteString(err.Error())
+		}
+	}
+	if h.todoSrc != nil {
+		h.todoSrc.RLock()
+		for e := h.todoSrc.Front(); e != nil; e = e.Next() {
+			c, ok := e.Value.(*srcEntry).src.(io.Closer)
+			if !ok {
+				continue
+			}
+			if err := c.Close(); err != nil {
+				if errBuf.Len() > 0 {
+					errBuf.WriteString("; ")
+				}
+				errBuf.WriteString(err.Error())
+			}
+		}
+		h.todoSrc.RUnlock()
+	}
+	if errBuf.Len() > 0 {
+		return errors.New(errBuf.String())
+	}
+	return nil
+}
+
+// flush 刷新所有实现了 Flusher 的处理器和输出。
+func (h *Handlers) flush() error {
+	return flushAll(append(h.handlerFlushers(), h.sinkFlushers()...))
+}
+
+// handlerFlushers 返回处理链中所有实现了 Flusher 的处理器。
+func (h *Handlers) handlerFlushers() []Flusher {
+	if h.handlers == nil {
+		return nil
+	}
+	h.handlers.RLock()
+	defer h.handlers.RUnlock()
+	var flushers []Flusher
+	for e := h.handlers.Front(); e != nil; e = e.Next() {
+		if f, ok := e.Value.(Flusher); ok {
+			flushers = append(flushers, f)
+		}
+	}
+	return flushers
+}
+
+// sinkFlushers 返回所有实现了 Flusher 的输出。
+func (h *Handlers) sinkFlushers() []Flusher {
+	h.RLock()
+	defer h.RUnlock()
+	var flushers []Flusher
+	for _, s := range h.sinks {
+		if f, ok := s.(Flusher); ok {
+			flushers = append(flushers, f)
+		}
+	}
+	return flushers
+}
+
+// flushAll 依次刷新 flushers，合并所有错误。
+func flushAll(flushers []Flusher) error {
+	errBuf := bytes.Buffer{}
+	for _, f := range flushers {
+		if err := f.Flush(); err != nil {
+			if errBuf.Len() > 0 {
+				errBuf.WriteString("; ")
+			}
+			errBuf.WriteString(err.Error())
+		}
+	}
+	if errBuf.Len() > 0 {
+		return errors.New(errBuf.String())
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"container/list"
+	"errors"
+)
+
+// Emit 处理器返回 Emit 时，其中的每个元素依次作为一条数据交给后面的处理器，
+// 返回空的 Emit 等同于返回 None。
+type Emit []interface{}
+
+// None 处理器返回 None 时丢弃当前数据，不再交给后面的处理器。
+var None interface{} = dropped{}
+
+type dropped struct{}
+
+// ErrSkip 处理器返回 ErrSkip（或包装了它的错误）时丢弃当前数据，效果和返回 None 相同，
+// 不会中止数据源，也不会交给 Rejecter，便于用返回错误的方式编写过滤器。
+var ErrSkip = errors.New("handlers: skip item")
+
+// emitFrom 把处理器的输出 out 从处理链的 e 处开始处理，展开 Emit 并丢弃 None。
+func (h *Handlers) emitFrom(fl *flight, e *list.Element, out interface{}) error {
+	switch v := out.(type) {
+	case Emit:
+		for _, item := range v {
+			if err := h.emitFrom(fl, e, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case dropped:
+		return nil
+	}
+	return h.handleFrom(fl, e, out)
+}
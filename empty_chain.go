@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// EmptyChainMode 处理链中没有处理器（也没有输出）时的行为。
+type EmptyChainMode int
+
+// 处理链中没有处理器时的行为
+const (
+	EmptyChainSkip  EmptyChainMode = iota // 不读取数据源，直接视为处理完毕（默认）
+	EmptyChainDrain                       // 读取并丢弃数据源中的所有数据，数量由 DiscardedItems 返回
+	EmptyChainError                       // Run 不处理任何数据源，返回 ErrEmptyChain
+)
+
+// ErrEmptyChain 处理链中没有处理器。
+var ErrEmptyChain = errors.New("handlers: no handlers registered")
+
+// SetEmptyChainMode 设置处理链中没有处理器时的行为。
+func (h *Handlers) SetEmptyChainMode(mode EmptyChainMode) {
+	h.Lock()
+	h.emptyMode = mode
+	h.Unlock()
+}
+
+// DiscardedItems 返回 EmptyChainDrain 模式下丢弃的数据条数。
+func (h *Handlers) DiscardedItems() int64 {
+	return atomic.LoadInt64(&h.discarded)
+}
+
+// chainEmpty 处理链中没有处理器也没有输出。
+func (h *Handlers) chainEmpty() bool {
+	return listLen(h.handlers) == 0 && !h.hasSinks()
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrorAction 处理器出错时的处理方式。
+type ErrorAction int
+
+const (
+	ErrorAbort ErrorAction = iota // 返回错误，和没有设置策略时一样交给 Rejecter 和 ErrCheck（默认）
+	ErrorSkip                     // 丢弃当前数据，继续处理后续数据
+	ErrorRetry                    // 重试，最多 MaxRetries 次，仍然失败时按 Exhausted 处理
+)
+
+// ErrorPolicy 单个处理器的出错策略。
+type ErrorPolicy struct {
+	OnError    ErrorAction
+	MaxRetries int
+	// Backoff 重试前的等待时间，为 nil 时立即重试。
+	Backoff BackoffFunc
+	// Exhausted 重试次数用完后的处理方式，只能是 ErrorAbort 或 ErrorSkip。
+	Exhausted ErrorAction
+	// OnSkip 数据因出错被丢弃时调用，可用于记录日志或计数。
+	OnSkip func(in interface{}, err error)
+}
+
+// policyStage 按 ErrorPolicy 处理出错的处理器。
+type policyStage struct {
+	stage
+	p ErrorPolicy
+}
+
+// AddHandlerWithPolicy 添加处理器，该处理器出错时按 p 处理，不影响其他处理器。
+// 返回 ErrSkip 和 context 结束的错误不受策略影响。
+func (h *Handlers) AddHandlerWithPolicy(handler Handler, p ErrorPolicy) {
+	h.AddHandler(&policyStage{stage: stage{h: handler}, p: p})
+}
+
+// Handle 实现 Handler 接口。
+func (s *policyStage) Handle(in interface{}) (interface{}, error) {
+	out, err := callHandler(s.h, in)
+	if err == nil || !retryable(err) {
+		return out, err
+	}
+	action := s.p.OnError
+	if action == ErrorRetry {
+		for i := 0; i < s.p.MaxRetries; i++ {
+			if s.p.Backoff != nil {
+				time.Sleep(s.p.Backoff(i))
+			}
+			if out, err = callHandler(s.h, in); err == nil || !retryable(err) {
+				return out, err
+			}
+		}
+		action = s.p.Exhausted
+	}
+	if action != ErrorSkip {
+		return nil, err
+	}
+	if s.p.OnSkip != nil {
+		s.p.OnSkip(in, err)
+	}
+	return None, nil
+}
+
+// retryable 返回 err 是否可以按出错策略处理。
+func retryable(err error) bool {
+	return !errors.Is(err, ErrSkip) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// WithRetry 包装处理器，出错时按 backoff 等待后重新调用，最多共调用 attempts 次，
+// 仍然失败时返回最后一次的错误（交给 Rejecter 和 ErrCheck）。backoff 可以使用 NewBackoff 创建。
+func WithRetry(h Handler, attempts int, backoff BackoffFunc) Handler {
+	return &policyStage{
+		stage: stage{h: h},
+		p:     ErrorPolicy{OnError: ErrorRetry, MaxRetries: attempts - 1, Backoff: backoff},
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import "time"
+
+// EventKind 事件的类型。
+type EventKind int
+
+const (
+	EventRunStart        EventKind = iota // Run 开始
+	EventRunStop                          // Run 结束，Err 为 Run 返回的错误
+	EventSourceOpened                     // 开始处理一个源（同一个源只触发一次）
+	EventSourceExhausted                  // 源的数据读完，Err 为 Next 返回的错误，通常是 io.EOF
+	EventHandlerError                     // 处理器返回了错误（重试、Rejecter 等处理之前）
+	EventItemDropped                      // 处理器返回 None 或 ErrSkip 丢弃了数据
+)
+
+var eventNames = [...]string{"run_start", "run_stop", "source_opened", "source_exhausted", "handler_error", "item_dropped"}
+
+func (k EventKind) String() string {
+	if k >= 0 && int(k) < len(eventNames) {
+		return eventNames[k]
+	}
+	return "unknown"
+}
+
+// Event Run 过程中的事件，用于接入日志、告警、进度展示等。
+type Event struct {
+	Kind    EventKind
+	Time    time.Time
+	Source  Source      // 源相关的事件
+	Handler string      // 处理器相关的事件，为处理器的名称
+	Item    interface{} // EventHandlerError 和 EventItemDropped 时为处理的数据
+	Items   int64       // EventSourceExhausted 时为源读取的数据条数
+	Err     error
+}
+
+// OnEvent 添加事件监听器，监听器在触发事件的 goroutine 中同步调用，应尽快返回。
+// 同时处理多个源时监听器会被并发调用。RunChainOn 不触发事件。
+func (h *Handlers) OnEvent(fn func(Event)) {
+	h.Lock()
+	defer h.Unlock()
+	old, _ := h.listeners.Load().([]func(Event))
+	listeners := make([]func(Event), len(old), len(old)+1)
+	copy(listeners, old)
+	h.listeners.Store(append(listeners, fn))
+}
+
+// hasListeners 是否添加了事件监听器。
+func (h *Handlers) hasListeners() bool {
+	listeners, _ := h.listeners.Load().([]func(Event))
+	return len(listeners) > 0 || h.loadLogger() != nil
+}
+
+// emitEvent 把事件交给所有监听器。
+func (h *Handlers) emitEvent(ev Event) {
+	if l := h.loadLogger(); l != nil {
+		logEvent(l, ev)
+	}
+	listeners, _ := h.listeners.Load().([]func(Event))
+	for _, fn := range listeners {
+		fn(ev)
+	}
+}
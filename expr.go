@@ -0,0 +1,536 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr 编译后的表达式，可以对 map 或结构体数据求值，例如：
+//
+//	status >= 500 && service == "api"
+//	!(user.name == "root") || len > 10 * 1024
+//
+// 支持数字、字符串（单引号或双引号）、true、false、nil 字面量，
+// 运算符 || && ! == != < <= > >= + - * / % 和括号。
+// 标识符按 . 分隔的路径从数据中取值，map 按键取值，结构体按字段名或 json 标签取值，不存在时为 nil。
+// 整数和浮点数统一按 float64 比较。
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+// CompileExpr 编译表达式。
+func CompileExpr(src string) (*Expr, error) {
+	p := &exprParser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("expr %q: unexpected %q", src, p.tokens[p.pos].text)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// MustCompileExpr 和 CompileExpr 相同，编译失败时 panic。
+func MustCompileExpr(src string) *Expr {
+	e, err := CompileExpr(src)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// String 返回表达式的源码。
+func (e *Expr) String() string { return e.src }
+
+// Eval 对数据 item 求值。
+func (e *Expr) Eval(item interface{}) (interface{}, error) {
+	v, err := e.root.eval(item)
+	if err != nil {
+		return nil, fmt.Errorf("expr %q: %v", e.src, err)
+	}
+	return v, nil
+}
+
+// Bool 对数据 item 求值，结果必须是 bool。
+func (e *Expr) Bool(item interface{}) (bool, error) {
+	v, err := e.Eval(item)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expr %q: result %v is not bool", e.src, v)
+	}
+	return b, nil
+}
+
+// NewExprFilter 新建过滤处理器，丢弃表达式结果为 false 的数据。
+func NewExprFilter(src string) (Handler, error) {
+	e, err := CompileExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	return HandlerFunc(func(in interface{}) (interface{}, error) {
+		ok, err := e.Bool(in)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return None, nil
+		}
+		return in, nil
+	}), nil
+}
+
+// ExprRoute 路由规则，表达式结果为 true 的数据发送到名为 Output 的旁路输出。
+type ExprRoute struct {
+	Expr   string
+	Output string
+}
+
+// ExprRouter 按表达式把数据路由到旁路输出，数据发送到第一个匹配的规则，
+// 没有匹配的规则时交给后面的处理器。
+type ExprRouter struct {
+	exprs   []*Expr
+	outputs []string
+	emit    SideEmitter
+}
+
+// NewExprRouter 新建路由处理器，按顺序匹配 routes。
+func NewExprRouter(routes ...ExprRoute) (*ExprRouter, error) {
+	r := &ExprRouter{}
+	for _, route := range routes {
+		e, err := CompileExpr(route.Expr)
+		if err != nil {
+			return nil, err
+		}
+		r.exprs = append(r.exprs, e)
+		r.outputs = append(r.outputs, route.Output)
+	}
+	return r, nil
+}
+
+// SetSideEmitter 实现 SideOutputHandler 接口。
+func (r *ExprRouter) SetSideEmitter(emit SideEmitter) {
+	r.emit = emit
+}
+
+// Handle 实现 Handler 接口。
+func (r *ExprRouter) Handle(in interface{}) (interface{}, error) {
+	for i, e := range r.exprs {
+		ok, err := e.Bool(in)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if r.emit == nil {
+			return nil, fmt.Errorf("expr router: side output %q not bound", r.outputs[i])
+		}
+		if err = r.emit(r.outputs[i], in); err != nil {
+			return nil, err
+		}
+		return None, nil
+	}
+	return in, nil
+}
+
+// 词法分析
+
+type exprTokenKind int
+
+const (
+	tokNumber exprTokenKind = iota
+	tokString
+	tokIdent
+	tokOp
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	num  float64
+}
+
+type exprParser struct {
+	src    string
+	tokens []exprToken
+	pos    int
+}
+
+var exprOps = []string{"||", "&&", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")"}
+
+func (p *exprParser) lex() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.' || s[j] == 'e' || s[j] == 'E' ||
+				(s[j] == '-' || s[j] == '+') && (s[j-1] == 'e' || s[j-1] == 'E')) {
+				j++
+			}
+			n, err := strconv.ParseFloat(s[i:j], 64)
+			if err != nil {
+				return fmt.Errorf("expr %q: bad number %q", p.src, s[i:j])
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokNumber, text: s[i:j], num: n})
+			i = j
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(s) && s[j] != s[i] {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return fmt.Errorf("expr %q: unterminated string", p.src)
+			}
+			text := s[i : j+1]
+			if c == '\'' {
+				text = `"` + strings.Replace(text[1:len(text)-1], `"`, `\"`, -1) + `"`
+			}
+			str, err := strconv.Unquote(text)
+			if err != nil {
+				return fmt.Errorf("expr %q: bad string %s", p.src, s[i:j+1])
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokString, text: str})
+			i = j + 1
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokIdent, text: s[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, o := range exprOps {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("expr %q: unexpected character %q", p.src, c)
+			}
+			p.tokens = append(p.tokens, exprToken{kind: tokOp, text: op})
+			i += len(op)
+		}
+	}
+	return nil
+}
+
+// 语法分析，优先级从低到高：|| && 比较 加减 乘除 一元
+
+func (p *exprParser) peekOp(ops ...string) string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokOp {
+		return ""
+	}
+	for _, op := range ops {
+		if p.tokens[p.pos].text == op {
+			return op
+		}
+	}
+	return ""
+}
+
+func (p *exprParser) parseBinary(next func() (exprNode, error), ops ...string) (exprNode, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peekOp(ops...); op != ""; op = p.peekOp(ops...) {
+		p.pos++
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprBinary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	return p.parseBinary(p.parseCompare, "&&")
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	return p.parseBinary(p.parseAdd, "==", "!=", "<=", ">=", "<", ">")
+}
+
+func (p *exprParser) parseAdd() (exprNode, error) {
+	return p.parseBinary(p.parseMul, "+", "-")
+}
+
+func (p *exprParser) parseMul() (exprNode, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if op := p.peekOp("!", "-"); op != "" {
+		p.pos++
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprUnary{op: op, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("expr %q: unexpected end", p.src)
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	switch t.kind {
+	case tokNumber:
+		return exprLiteral{t.num}, nil
+	case tokString:
+		return exprLiteral{t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return exprLiteral{true}, nil
+		case "false":
+			return exprLiteral{false}, nil
+		case "nil", "null":
+			return exprLiteral{nil}, nil
+		}
+		return exprField(strings.Split(t.text, ".")), nil
+	}
+	if t.text == "(" {
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peekOp(")") == "" {
+			return nil, fmt.Errorf("expr %q: missing )", p.src)
+		}
+		p.pos++
+		return x, nil
+	}
+	return nil, fmt.Errorf("expr %q: unexpected %q", p.src, t.text)
+}
+
+// 求值
+
+type exprNode interface {
+	eval(item interface{}) (interface{}, error)
+}
+
+type exprLiteral struct{ v interface{} }
+
+func (l exprLiteral) eval(interface{}) (interface{}, error) { return l.v, nil }
+
+type exprField []string
+
+func (f exprField) eval(item interface{}) (interface{}, error) {
+	v := item
+	for _, name := range f {
+		v = lookupField(v, name)
+		if v == nil {
+			return nil, nil
+		}
+	}
+	return normalizeValue(v), nil
+}
+
+// lookupField 从 map 或结构体中取名为 name 的值，不存在时返回 nil。
+func lookupField(v interface{}, name string) interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m[name]
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		mv := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !mv.IsValid() {
+			return nil
+		}
+		return mv.Interface()
+	case reflect.Struct:
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			sf := rt.Field(i)
+			if sf.PkgPath != "" {
+				continue
+			}
+			tag := strings.Split(sf.Tag.Get("json"), ",")[0]
+			if sf.Name == name || tag == name {
+				return rv.Field(i).Interface()
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeValue 把各种数字类型转换为 float64。
+func normalizeValue(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	}
+	if n, ok := v.(interface{ Float64() (float64, error) }); ok { // 例如 json.Number
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
+
+type exprUnary struct {
+	op string
+	x  exprNode
+}
+
+func (u *exprUnary) eval(item interface{}) (interface{}, error) {
+	v, err := u.x.eval(item)
+	if err != nil {
+		return nil, err
+	}
+	if u.op == "!" {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator ! on non-bool %v", v)
+		}
+		return !b, nil
+	}
+	n, ok := v.(float64)
+	if !ok {
+		return nil, fmt.Errorf("operator - on non-number %v", v)
+	}
+	return -n, nil
+}
+
+type exprBinary struct {
+	op          string
+	left, right exprNode
+}
+
+func (b *exprBinary) eval(item interface{}) (interface{}, error) {
+	l, err := b.left.eval(item)
+	if err != nil {
+		return nil, err
+	}
+	// && 和 || 短路求值
+	if b.op == "&&" || b.op == "||" {
+		lb, ok := l.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator %s on non-bool %v", b.op, l)
+		}
+		if lb == (b.op == "||") {
+			return lb, nil
+		}
+		r, err := b.right.eval(item)
+		if err != nil {
+			return nil, err
+		}
+		rb, ok := r.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator %s on non-bool %v", b.op, r)
+		}
+		return rb, nil
+	}
+	r, err := b.right.eval(item)
+	if err != nil {
+		return nil, err
+	}
+	switch b.op {
+	case "==":
+		return reflect.DeepEqual(l, r), nil // JSON 解码的 slice、map 不能用 == 比较
+	case "!=":
+		return !reflect.DeepEqual(l, r), nil
+	}
+
+	if ls, ok := l.(string); ok {
+		rs, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("operator %s on mismatched types %v and %v", b.op, l, r)
+		}
+		switch b.op {
+		case "<":
+			return ls < rs, nil
+		case "<=":
+			return ls <= rs, nil
+		case ">":
+			return ls > rs, nil
+		case ">=":
+			return ls >= rs, nil
+		case "+":
+			return ls + rs, nil
+		}
+		return nil, fmt.Errorf("operator %s on strings", b.op)
+	}
+
+	ln, lok := l.(float64)
+	rn, rok := r.(float64)
+	if !lok || !rok {
+		return nil, fmt.Errorf("operator %s on non-numbers %v and %v", b.op, l, r)
+	}
+	switch b.op {
+	case "<":
+		return ln < rn, nil
+	case "<=":
+		return ln <= rn, nil
+	case ">":
+		return ln > rn, nil
+	case ">=":
+		return ln >= rn, nil
+	case "+":
+		return ln + rn, nil
+	case "-":
+		return ln - rn, nil
+	case "*":
+		return ln * rn, nil
+	case "/":
+		if rn == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return ln / rn, nil
+	case "%":
+		if rn == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(ln, rn), nil
+	}
+	return nil, fmt.Errorf("unknown operator %s", b.op)
+}
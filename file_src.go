@@ -8,35 +8,96 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"sync/atomic"
+	"time"
 )
 
 // FileSource 文件源，按行读取。
 type FileSource struct {
-	file *os.File
-	r    *bufio.Reader
+	file   *os.File
+	r      *bufio.Reader
+	path   string
+	offset int64 // 已经读取到的位置
+	limit  int64 // 大于 0 时只读取起始位置小于 limit 的行
+	start  int64 // 开始读取的位置
+	line   int64 // 从 lineFrom 开始已经读取的行数
+	size   int64 // 打开时的文件大小
+
+	lineFrom int64 // 开始计算 line 的位置，总在行首
+	lineBase int64 // lineFrom 之前的行数，-1 表示还没有计算
+
+	read     int64 // offset 的副本，供 Progress 并发读取
+	begin    int64 // 第一次调用 Next 的时间（UnixNano）
+	progress progressReporter
 }
 
 // NewFileSrc 新建文件源
 func NewFileSrc(filePath string) (*FileSource, error) {
+	return newFileSrcAt(filePath, 0)
+}
+
+// newFileSrcAt 新建文件源，从 offset 处开始读取。
+func newFileSrcAt(filePath string, offset int64) (*FileSource, error) {
 	file, err := os.OpenFile(filePath, os.O_RDONLY, os.ModePerm)
 	if err != nil {
 		return nil, err
 	}
-	return &FileSource{
-		file: file,
-		r:    bufio.NewReader(file),
-	}, nil
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err = file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	fs := &FileSource{
+		file:     file,
+		r:        bufio.NewReader(file),
+		path:     filePath,
+		offset:   offset,
+		read:     offset,
+		start:    offset,
+		size:     info.Size(),
+		lineFrom: offset,
+	}
+	if offset > 0 {
+		fs.lineBase = -1
+	}
+	return fs, nil
 }
 
 // Next 实现 Source 接口。
 func (fs *FileSource) Next() (data interface{}, err error) {
+	if fs.limit > 0 && fs.offset >= fs.limit {
+		fs.Close()
+		return "", io.EOF
+	}
+	atomic.CompareAndSwapInt64(&fs.begin, 0, time.Now().UnixNano())
 	line, err := fs.r.ReadString('\n')
+	fs.offset += int64(len(line))
+	atomic.StoreInt64(&fs.read, fs.offset)
+	if len(line) > 0 {
+		fs.line++
+	}
 	if err != nil {
 		fs.Close()
 	}
+	fs.progress.report(fs.Progress, err != nil)
 	return line, err
 }
 
+// Health 实现 HealthChecker 接口，检查文件是否仍然可读。
+func (fs *FileSource) Health() error {
+	if fs.file == nil {
+		return errors.New("file source closed")
+	}
+	_, err := fs.file.Stat()
+	return err
+}
+
 // Close 关闭文件，可以主动关闭，调用 Next 的过程中如果产生错误会自动关闭。
 func (fs *FileSource) Close() error {
 	if fs.file != nil {
@@ -49,8 +110,13 @@ func (fs *FileSource) Close() error {
 
 // MultiFileSrc 多文件源
 type MultiFileSrc struct {
-	src   []*FileSource
-	index int
+	src     []*FileSource
+	index   int
+	pattern string
+
+	cur      int32 // index 的副本，供 Progress 并发读取
+	begin    int64 // 第一次调用 Next 的时间（UnixNano）
+	progress progressReporter
 }
 
 // NewMultiFileSrc 创建多文件源，filesPattern 的意义和 filepath.Glob 相同。
@@ -59,7 +125,7 @@ func NewMultiFileSrc(filesPattern string) (*MultiFileSrc, error) {
 	if err != nil {
 		return nil, err
 	}
-	mfs := &MultiFileSrc{}
+	mfs := &MultiFileSrc{pattern: filesPattern}
 	mfs.src = make([]*FileSource, len(files))
 	for i, file := range files {
 		mfs.src[i], err = NewFileSrc(file)
@@ -76,16 +142,29 @@ func (mfs *MultiFileSrc) Next() (data interface{}, err error) {
 	if mfs.index >= len(mfs.src) {
 		return nil, io.EOF
 	}
+	atomic.CompareAndSwapInt64(&mfs.begin, 0, time.Now().UnixNano())
 	data, err = mfs.src[mfs.index].Next()
 	if err != nil {
 		mfs.index++
+		atomic.StoreInt32(&mfs.cur, int32(mfs.index))
 		if err == io.EOF && mfs.index < len(mfs.src) {
 			err = nil
 		}
 	}
+	mfs.progress.report(mfs.Progress, err != nil)
 	return
 }
 
+// Health 实现 HealthChecker 接口，检查尚未读完的文件。
+func (mfs *MultiFileSrc) Health() error {
+	for i := mfs.index; i < len(mfs.src); i++ {
+		if err := mfs.src[i].Health(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Close 关闭。
 func (mfs *MultiFileSrc) Close() error {
 	errBuf := bytes.Buffer{}
@@ -0,0 +1,50 @@
+package handlers
+
+import "sync"
+
+// SetWatermarks 限制同时处理中的数据条数。处理中的数据达到 high 条时暂停从数据源拉取，
+// 直到回落到 low 条以下再恢复。high <= 0 表示不限制；low 不合法时取 high-1。
+func (h *Handlers) SetWatermarks(high, low int) {
+	if low < 0 || low >= high {
+		low = high - 1
+	}
+	h.flightMu.Lock()
+	h.highMark, h.lowMark = high, low
+	h.cond().Broadcast()
+	h.flightMu.Unlock()
+}
+
+// InFlight 返回正在处理中的数据条数。
+func (h *Handlers) InFlight() int {
+	h.flightMu.Lock()
+	defer h.flightMu.Unlock()
+	return h.inFlight
+}
+
+// cond 调用方需持有 h.flightMu。
+func (h *Handlers) cond() *sync.Cond {
+	if h.flightCond == nil {
+		h.flightCond = sync.NewCond(&h.flightMu)
+	}
+	return h.flightCond
+}
+
+// acquire 登记一条开始处理的数据，超过高水位时阻塞直到回落到低水位。
+func (h *Handlers) acquire() {
+	h.flightMu.Lock()
+	if h.highMark > 0 && h.inFlight >= h.highMark {
+		for h.highMark > 0 && h.inFlight > h.lowMark {
+			h.cond().Wait()
+		}
+	}
+	h.inFlight++
+	h.flightMu.Unlock()
+}
+
+// release 登记一条数据处理完毕。
+func (h *Handlers) release() {
+	h.flightMu.Lock()
+	h.inFlight--
+	h.cond().Broadcast()
+	h.flightMu.Unlock()
+}
@@ -0,0 +1,3 @@
+module github.com/qn-zyc/handlers
+
+go 1.18
@@ -2,16 +2,22 @@ package handlers
 
 import (
 	"container/list"
+	"context"
 	"errors"
 	"io"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Handlers 的状态
 const (
-	StatusInit    int32 = iota // 初始化中
-	StatusRunning              // 正在运行
-	StatusStop                 // 已停止
+	StatusInit     int32 = iota // 初始化中
+	StatusRunning               // 正在运行
+	StatusStop                  // 已停止
+	StatusDraining              // 正在 Drain，不再拉取新数据
+	StatusFailed                // Run 返回了错误
+	StatusPaused                // 已暂停，Resume 后继续拉取数据
 )
 
 // Source 数据源
@@ -22,6 +28,7 @@ type Source interface {
 // Handler 处理器
 type Handler interface {
 	// Handle 处理输入数据，返回的数据将用于下一个处理器。
+	// 返回 Emit 可以产生多条数据，返回 None 则丢弃当前数据。
 	Handle(in interface{}) (dataForNextHandler interface{}, err error)
 }
 
@@ -45,41 +52,130 @@ func newSafeList() *safeList {
 // Handlers 处理器集合。
 type Handlers struct {
 	sync.RWMutex
-	todoSrc  *safeList // 未处理源
-	doneSrc  *safeList // 已处理源
+	todoSrc  *safeList // 未处理源，元素为 *srcEntry
+	doneSrc  *safeList // 已处理源，元素为 *srcEntry
 	handlers *safeList // 处理链
 	state    int32     // Handlers的状态
+	// ErrCheck 判断源出错后是否继续处理其他源，源正常结束（io.EOF）时不调用。
+	// 默认忽略错误继续处理其他源；设置为 StopOnError 时遇到错误即停止，Run 返回该错误。
 	ErrCheck func(err error) (goon bool)
+
+	OnRunComplete func(sum RunSummary)           // Run 成功结束时调用
+	OnRunFailed   func(sum RunSummary)           // Run 返回错误时调用
+	OnStateChange func(oldState, newState int32) // 状态变化时调用
+
+	done     chan struct{}   // Run 返回时关闭
+	runCtx   context.Context // 当前 Run 的 context
+	draining int32           // 为 1 时停止拉取新数据
+	stopping int32           // 为 1 时表示调用了 Stop
+	pauseCh  chan struct{}   // 暂停时不为 nil，恢复时关闭
+	daemon   bool            // 为 true 时待处理队列为空也不结束 Run
+	srcWake  chan struct{}   // 守护模式下等待新数据源，添加数据源时关闭
+	quantum  int             // 每个源连续处理的数据条数，0 表示处理完再切换
+	workers  int             // 同时处理的源的数量
+
+	pipelined bool // 是否启用流水线模式
+	pipeBuf   int  // 流水线各级之间 channel 的容量
+
+	emptyMode EmptyChainMode  // 处理链为空时的行为
+	strict    int32           // 为 1 时检查 TypedHandler 的类型
+	rejecter  Rejecter        // 不为 nil 时处理失败的数据交给它，而不是中止数据源
+	ckpt      CheckpointStore // SetCheckpoint 设置的进度存储
+	ckptEvery time.Duration   // 保存进度的间隔
+	profile   string          // 当前的配置名称
+	discarded int64           // 处理链为空时丢弃的数据条数
+
+	sideOutputs map[string]Handler       // 旁路输出
+	chainMu     sync.Mutex               // 串行化对处理链的修改
+	names       map[string]*list.Element // AddNamedHandler 添加的处理器，由 chainMu 保护
+	middleware  []Middleware             // Use 添加的中间件
+	wrapped     atomic.Value             // 中间件包装后的处理器，类型为 map[*list.Element]Handler
+	stats       runStats                 // Stats 的计数
+	listeners   atomic.Value             // OnEvent 添加的监听器，类型为 []func(Event)
+	logger      atomic.Value             // SetLogger 设置的日志，类型为 loggerBox
+	sinks       []Sink                   // 处理链的输出
+	durable     []SyncSink               // AddDurableSink 添加的需要确认写入的输出
+
+	markerMu  sync.Mutex
+	markers   []Marker      // 等待注入的控制标记
+	heartbeat time.Duration // 注入心跳标记的间隔，0 表示不注入
+
+	activeMu sync.Mutex  // 保护 active，以及源在 active 和两个队列之间的移动
+	active   []*srcEntry // 正在处理的源
+
+	flightMu   sync.Mutex
+	flightCond *sync.Cond
+	inFlight   int // 正在处理链中的数据条数
+	highMark   int // 高水位，inFlight 达到它时暂停拉取数据
+	lowMark    int // 低水位，inFlight 回落到它时恢复拉取数据
 }
 
 // AddSrc 添加待处理的数据源
 func (h *Handlers) AddSrc(src Source) {
+	// 守护模式下可能和 Run 同时调用，需要在锁内读取 todoSrc。
+	h.Lock()
 	if h.todoSrc == nil {
-		h.Lock()
-		if h.todoSrc == nil {
-			h.todoSrc = newSafeList()
-		}
-		h.Unlock()
+		h.todoSrc = newSafeList()
 	}
-	h.todoSrc.Lock()
-	h.todoSrc.PushBack(src)
-	h.todoSrc.Unlock()
+	todo := h.todoSrc
+	h.Unlock()
+	todo.Lock()
+	todo.PushBack(&srcEntry{src: src})
+	todo.Unlock()
+	h.wakeSrc()
 }
 
-// popSrc 获取一个待处理源。
-func (h *Handlers) popSrc() Source {
+// popSrc 获取一个待处理源，并记为正在处理。
+func (h *Handlers) popSrc() *srcEntry {
 	if h.todoSrc == nil {
 		return nil
 	}
+	h.activeMu.Lock()
+	defer h.activeMu.Unlock()
 	h.todoSrc.Lock()
+	defer h.todoSrc.Unlock()
 	ele := h.todoSrc.Front()
+	if ele == nil {
+		return nil
+	}
 	h.todoSrc.Remove(ele)
+	ent := ele.Value.(*srcEntry)
+	h.active = append(h.active, ent)
+	return ent
+}
+
+// inactive 把 src 从正在处理的源中去掉，调用方需持有 h.activeMu。
+func (h *Handlers) inactive(src *srcEntry) {
+	for i, ent := range h.active {
+		if ent == src {
+			h.active = append(h.active[:i], h.active[i+1:]...)
+			return
+		}
+	}
+}
+
+// pushSrcBack 把未处理完的源放回队尾，轮到它时继续处理。
+func (h *Handlers) pushSrcBack(src *srcEntry) {
+	h.activeMu.Lock()
+	defer h.activeMu.Unlock()
+	h.inactive(src)
+	h.todoSrc.Lock()
+	h.todoSrc.PushBack(src)
+	h.todoSrc.Unlock()
+}
+
+// pushSrcFront 把未处理完的源放回队首，下次 Run 时优先处理。
+func (h *Handlers) pushSrcFront(src *srcEntry) {
+	h.activeMu.Lock()
+	defer h.activeMu.Unlock()
+	h.inactive(src)
+	h.todoSrc.Lock()
+	h.todoSrc.PushFront(src)
 	h.todoSrc.Unlock()
-	return ele.Value.(Source)
 }
 
 // srcDone src已经处理完毕。
-func (h *Handlers) srcDone(src Source) {
+func (h *Handlers) srcDone(src *srcEntry) {
 	if h.doneSrc == nil {
 		h.Lock()
 		if h.doneSrc == nil {
@@ -87,13 +183,79 @@ func (h *Handlers) srcDone(src Source) {
 		}
 		h.Unlock()
 	}
+	h.activeMu.Lock()
+	defer h.activeMu.Unlock()
+	h.inactive(src)
 	h.doneSrc.Lock()
 	h.doneSrc.PushBack(src)
 	h.doneSrc.Unlock()
 }
 
+// SetQuantum 设置每个源连续处理的数据条数，达到后切换到下一个源，轮流处理所有源，
+// 避免单个源长时间独占。k <= 0 表示每个源处理完再处理下一个（默认）。
+func (h *Handlers) SetQuantum(k int) {
+	h.Lock()
+	h.quantum = k
+	h.Unlock()
+}
+
+// SetConcurrency 设置同时处理的源的数量，n <= 1 表示依次处理（默认）。
+// 同时处理多个源时处理器、输出和 ErrCheck 会被并发调用，需要自行保证并发安全。
+// 某个源返回 ErrCheck 不接受的错误时，其他正在处理的源停止并放回待处理队列，Run 返回该错误。
+func (h *Handlers) SetConcurrency(n int) {
+	h.Lock()
+	h.workers = n
+	h.Unlock()
+}
+
 // AddHandler 添加处理器。
 func (h *Handlers) AddHandler(handler Handler) {
+	h.editChain(func(l *list.List, names map[string]*list.Element) error {
+		l.PushBack(handler)
+		return nil
+	})
+}
+
+// editChain 写时复制地修改处理链：复制当前的处理链，对副本调用 fn，成功后用副本替换处理链。
+// 发布后的处理链不再修改，正在处理的数据沿着旧的处理链继续，所以修改不用等待数据源处理完，
+// 从下一条数据开始生效。names 为副本中带名称的处理器，fn 可以修改它。
+func (h *Handlers) editChain(fn func(l *list.List, names map[string]*list.Element) error) error {
+	sl := h.handlerList()
+	h.chainMu.Lock()
+	defer h.chainMu.Unlock()
+	sl.RLock()
+	old := sl.List
+	sl.RUnlock()
+	l := list.New()
+	elems := make(map[*list.Element]*list.Element, old.Len())
+	for e := old.Front(); e != nil; e = e.Next() {
+		elems[e] = l.PushBack(e.Value)
+	}
+	names := make(map[string]*list.Element, len(h.names))
+	for name, e := range h.names {
+		names[name] = elems[e]
+	}
+	if err := fn(l, names); err != nil {
+		return err
+	}
+	h.remapWrapped(elems)
+	h.remapStats(l, elems)
+	h.names = names
+	sl.Lock()
+	sl.List = l
+	sl.Unlock()
+	return nil
+}
+
+// chainFront 返回当前处理链的第一个处理器。处理链不会原地修改，之后沿着它遍历不需要加锁。
+func (h *Handlers) chainFront() *list.Element {
+	h.handlers.RLock()
+	defer h.handlers.RUnlock()
+	return h.handlers.Front()
+}
+
+// handlerList 返回处理链，需要时创建。
+func (h *Handlers) handlerList() *safeList {
 	if h.handlers == nil {
 		h.Lock()
 		if h.handlers == nil {
@@ -101,9 +263,7 @@ func (h *Handlers) AddHandler(handler Handler) {
 		}
 		h.Unlock()
 	}
-	h.handlers.Lock()
-	h.handlers.PushBack(handler)
-	h.handlers.Unlock()
+	return h.handlers
 }
 
 // AddHandlerFunc 添加处理器函数。
@@ -118,54 +278,361 @@ func (h *Handlers) defaultErrFunc(err error) (goon bool) {
 	return false
 }
 
-// Run 执行。
+// StopOnError 用作 ErrCheck，源出错时停止处理，Run 返回该错误。
+func StopOnError(err error) (goon bool) {
+	return err == nil
+}
+
+// errYield 内部使用，表示源已经连续处理了 quantum 条数据，需要让出给下一个源。
+var errYield = errors.New("handlers source yield")
+
+// Run 执行，等同于 RunContext(context.Background())。
 func (h *Handlers) Run() error {
+	return h.RunContext(context.Background())
+}
+
+// RunContext 执行，ctx 结束时停止从数据源拉取数据，并在处理器之间中止正在处理的数据，返回 ctx.Err()。
+// 未处理完的源放回待处理队列的队首，下次 Run 时从下一条数据继续处理。
+// 阻塞在 Next 中的数据源不会被打断。
+func (h *Handlers) RunContext(ctx context.Context) error {
 	// 防止多次调用Run().
-	// 初始化状态和停止状态都可以再次调用Run().
+	// 初始化、停止和失败状态都可以再次调用Run().
 	h.Lock()
-	if h.state == StatusRunning {
+	if isActive(h.state) {
 		h.Unlock()
 		return errors.New("handlers already running")
 	}
+	oldState := h.state
 	h.state = StatusRunning
+	onChange := h.OnStateChange
 
 	if h.ErrCheck == nil {
 		h.ErrCheck = h.defaultErrFunc
 	}
+	done := make(chan struct{})
+	h.done = done
+	h.runCtx = ctx
+	opts := &runOptions{
+		ctx:       ctx,
+		quantum:   h.quantum,
+		workers:   h.workers,
+		daemon:    h.daemon,
+		pipelined: h.pipelined,
+		pipeBuf:   h.pipeBuf,
+		heartbeat: h.heartbeat,
+		emptyMode: h.emptyMode,
+		rejecter:  h.rejecter,
+		ckpt:      h.ckpt,
+		ckptEvery: h.ckptEvery,
+		lastBeat:  time.Now(),
+	}
+	if h.handlers == nil {
+		h.handlers = newSafeList()
+	}
+	if h.todoSrc == nil {
+		h.todoSrc = newSafeList()
+	}
+	if h.doneSrc == nil {
+		// 在启动 worker 之前创建，避免多个 worker 在 srcDone 中同时创建。
+		h.doneSrc = newSafeList()
+	}
+	atomic.StoreInt32(&h.draining, 0)
+	atomic.StoreInt32(&h.stopping, 0)
+	h.resumeLocked()
 	h.Unlock()
+	defer close(done)
+	if onChange != nil && oldState != StatusRunning {
+		onChange(oldState, StatusRunning)
+	}
 
+	start := time.Now()
+	h.applyProfile()
+	h.applyMiddleware()
+	h.resetStats(start)
+	h.emitEvent(Event{Kind: EventRunStart, Time: start})
+	// 启动前检查健康状态，有不可用的源或处理器时直接失败。
+	err := h.Health()
+	if err == nil && h.isStrict() {
+		err = h.Validate()
+	}
+	if err == nil && opts.emptyMode == EmptyChainError && h.chainEmpty() {
+		err = ErrEmptyChain
+	}
+	if err == nil {
+		h.bindSideOutputs()
+		err = h.runSources(opts)
+		// 数据源处理完后写出缓存的数据：Drain 或 Stop 时源没有结束，先刷新处理器再刷新输出，否则只刷新输出。
+		flushers := h.sinkFlushers()
+		if atomic.LoadInt32(&h.draining) == 1 {
+			flushers = append(h.handlerFlushers(), flushers...)
+		}
+		if ferr := flushAll(flushers); err == nil {
+			err = ferr
+		}
+	}
+	if err == nil && atomic.LoadInt32(&h.stopping) == 1 {
+		err = ErrStopped
+	}
+	err = h.finishTx(err)
+	h.stats.finish()
+	if err != nil && err != ErrStopped {
+		h.setState(StatusFailed)
+	} else {
+		h.setState(StatusStop)
+	}
+	h.emitEvent(Event{Kind: EventRunStop, Time: time.Now(), Err: err})
+	h.notifyRun(start, err)
+	return err
+}
+
+// setState 修改状态并调用 OnStateChange。
+func (h *Handlers) setState(state int32) {
+	h.Lock()
+	old := h.state
+	h.state = state
+	onChange := h.OnStateChange
+	h.Unlock()
+	if onChange != nil && old != state {
+		onChange(old, state)
+	}
+}
+
+// runOptions Run 开始时读取的配置。
+type runOptions struct {
+	ctx       context.Context
+	quantum   int
+	workers   int
+	daemon    bool
+	pipelined bool
+	pipeBuf   int
+	heartbeat time.Duration
+	emptyMode EmptyChainMode
+	rejecter  Rejecter
+	ckpt      CheckpointStore
+	ckptEvery time.Duration
+	lastBeat  time.Time // 上次注入心跳标记的时间
+}
+
+// runSources 处理所有待处理源，opts.workers > 1 时同时处理多个源。
+func (h *Handlers) runSources(opts *runOptions) error {
+	if opts.workers <= 1 {
+		return h.runWorker(opts)
+	}
+	// 某个源返回 ErrCheck 不接受的错误时取消其他源，返回第一个错误。
+	ctx, cancel := context.WithCancel(opts.ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < opts.workers; i++ {
+		o := *opts
+		o.ctx = ctx
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := h.runWorker(&o); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// runWorker 依次处理待处理源，直到队列为空。
+func (h *Handlers) runWorker(opts *runOptions) error {
 	for {
 		src := h.popSrc()
 		if src == nil {
+			if opts.daemon && h.waitSrc(opts.ctx) {
+				continue
+			}
+			if opts.daemon {
+				if err := opts.ctx.Err(); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		err := h.handleSrc(src, opts)
+		if err == errDraining {
+			h.pushSrcFront(src)
 			break
 		}
-		err := h.handleSrc(src)
+		if err != nil && err == opts.ctx.Err() {
+			h.pushSrcFront(src)
+			return err
+		}
+		if err == errYield {
+			h.pushSrcBack(src)
+			continue
+		}
 		h.srcDone(src)
-		if err != nil && !h.ErrCheck(err) {
+		if err != nil && err != io.EOF && !h.ErrCheck(err) {
 			return err
 		}
 	}
 	return nil
 }
 
-func (h *Handlers) handleSrc(src Source) error {
-	if h.handlers == nil {
+// handleSrc 处理 ent 中的数据，opts.quantum > 0 时处理 quantum 条后返回 errYield。
+// opts.heartbeat > 0 时每隔 heartbeat 在数据之间注入一个心跳标记。
+func (h *Handlers) handleSrc(ent *srcEntry, opts *runOptions) error {
+	src := ent.src
+	empty := h.chainEmpty()
+	if empty && opts.emptyMode == EmptyChainSkip {
 		return nil
 	}
-	h.handlers.RLock()
-	defer h.handlers.RUnlock()
+	first := atomic.CompareAndSwapInt32(&ent.opened, 0, 1)
+	if first {
+		if err := h.restoreSrc(ent, opts); err != nil {
+			return wrapSrcErr(src, err)
+		}
+		h.emitEvent(Event{Kind: EventSourceOpened, Time: time.Now(), Source: src})
+	}
+	if err := h.beginSinks(src, !first || ent.restored); err != nil {
+		return wrapSrcErr(src, err)
+	}
+	// 返回前等待这个源的异步处理器的回调完成。
+	defer ent.fl.wg.Wait()
+	ent.fl.wait() // 清除上次处理这个源时已经报告过的错误
+	var p *pipeline
+	if opts.pipelined {
+		if p = h.startPipeline(opts.pipeBuf, &ent.fl); p != nil {
+			defer p.stop()
+		}
+	}
 
-	for d, err := src.Next(); ; d, err = src.Next() {
-		for e := h.handlers.Front(); e != nil; e = e.Next() {
-			if data, _err := e.Value.(Handler).Handle(d); _err == nil {
-				d = data
-			} else {
-				return _err
+	for n := 0; ; n++ {
+		h.waitPaused(opts.ctx)
+		if atomic.LoadInt32(&h.draining) == 1 {
+			return errDraining
+		}
+		if err := opts.ctx.Err(); err != nil {
+			return err
+		}
+		if opts.quantum > 0 && n >= opts.quantum {
+			return errYield
+		}
+		if opts.heartbeat > 0 && time.Since(opts.lastBeat) >= opts.heartbeat {
+			opts.lastBeat = time.Now()
+			h.InjectMarker(Marker{Kind: MarkerHeartbeat, Source: src, Time: opts.lastBeat})
+		}
+		if err := h.handlePendingMarkers(&ent.fl); err != nil {
+			return wrapSrcErr(src, err)
+		}
+		h.acquire()
+		d, err := src.Next()
+		// 源结束时（返回 err 的同时）返回的空数据不交给处理链。
+		skip := err != nil && isEmptyItem(d)
+		if !skip {
+			h.stats.read(ent.count(d))
+			if empty {
+				atomic.AddInt64(&h.discarded, 1)
 			}
 		}
+		var _err error
+		switch {
+		case skip:
+		case p != nil:
+			p.submit(d)
+		default:
+			_err = h.handle(&ent.fl, d)
+		}
+		h.release()
+		if _err != nil && _err == opts.ctx.Err() {
+			return _err
+		}
+		if _err != nil && opts.rejecter != nil {
+			_err = h.reject(opts.rejecter, ent, d, _err)
+		}
+		if _err == nil {
+			_err = ent.fl.takeErr()
+		}
+		if _err == nil && err == nil {
+			_err = h.checkpoint(ent, opts, false)
+		}
+		if _err != nil {
+			return wrapSrcErr(src, _err)
+		}
 		// 可能 err == io.EOF, 但是还是有数据产生。
 		if err != nil {
+			if _err = ent.fl.wait(); _err != nil {
+				return wrapSrcErr(src, _err)
+			}
+			if _err = h.handlePendingMarkers(&ent.fl); _err != nil {
+				return wrapSrcErr(src, _err)
+			}
+			if _err = h.handleMarker(&ent.fl, Marker{Kind: MarkerEndOfSource, Source: src, Time: time.Now()}); _err != nil {
+				return wrapSrcErr(src, _err)
+			}
+			if err == io.EOF {
+				if _err = h.confirmSrc(src); _err != nil {
+					return wrapSrcErr(src, _err)
+				}
+				if _err = h.checkpoint(ent, opts, true); _err != nil {
+					return wrapSrcErr(src, _err)
+				}
+			}
+			h.emitEvent(Event{Kind: EventSourceExhausted, Time: time.Now(), Source: src, Items: atomic.LoadInt64(&ent.items), Err: err})
+			return err
+		}
+	}
+}
+
+// ctxErr 当前 Run 的 context 结束时返回 ctx.Err()。
+func (h *Handlers) ctxErr() error {
+	if h.runCtx == nil {
+		return nil
+	}
+	select {
+	case <-h.runCtx.Done():
+		return h.runCtx.Err()
+	default:
+		return nil
+	}
+}
+
+// handle 将一条数据依次交给当前的处理链。
+// fl 为数据所属的源的 flight，可以为 nil。
+func (h *Handlers) handle(fl *flight, d interface{}) error {
+	return h.handleFrom(fl, h.chainFront(), d)
+}
+
+// handleFrom 将一条数据从处理链的 e 处开始依次处理。
+func (h *Handlers) handleFrom(fl *flight, e *list.Element, d interface{}) error {
+	strict := h.isStrict()
+	for ; e != nil; e = e.Next() {
+		if err := h.ctxErr(); err != nil {
+			return err
+		}
+		if as, ok := e.Value.(*asyncStage); ok {
+			h.dispatchAsync(fl, e, as, d)
+			return nil
+		}
+		if th, ok := e.Value.(TypedHandler); ok && strict {
+			if err := checkInType(th, d); err != nil {
+				return err
+			}
+		}
+		data, err := callHandler(h.handlerAt(e), d)
+		h.observe(e, d, data, err)
+		if err != nil {
+			if errors.Is(err, ErrSkip) {
+				return nil
+			}
 			return err
 		}
+		switch data.(type) {
+		case Emit, dropped:
+			return h.emitFrom(fl, e.Next(), data)
+		}
+		d = data
 	}
+	return h.writeSinks(d)
 }
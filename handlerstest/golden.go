@@ -0,0 +1,119 @@
+// Package handlerstest 提供测试处理链的工具。
+package handlerstest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// UpdateEnv 设置为非空（0 和 false 除外）时 Check 用实际输出覆盖 golden 文件。
+const UpdateEnv = "HANDLERSTEST_UPDATE"
+
+func update() bool {
+	v := os.Getenv(UpdateEnv)
+	return v != "" && v != "0" && v != "false"
+}
+
+// Golden 收集处理链的输出，与 golden 文件比较。
+// 放在处理链的最后，每条数据按 fmt.Sprint 格式化为一行（字符串和 []byte 原样写入，末尾补换行）。
+// 运行 HANDLERSTEST_UPDATE=1 go test 时用实际输出覆盖 golden 文件。
+type Golden struct {
+	path string
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+// NewGolden 新建 golden 比较器，path 通常位于 testdata 目录下。
+func NewGolden(path string) *Golden {
+	return &Golden{path: path}
+}
+
+// Handle 实现 handlers.Handler 接口，记录数据后原样返回。
+func (g *Golden) Handle(in interface{}) (interface{}, error) {
+	var s string
+	switch v := in.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	g.mu.Lock()
+	g.buf.WriteString(s)
+	g.mu.Unlock()
+	return in, nil
+}
+
+// Output 返回目前收集到的输出。
+func (g *Golden) Output() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.buf.String()
+}
+
+// Check 比较收集到的输出和 golden 文件，不一致时报告逐行的差异。
+func (g *Golden) Check(t testing.TB) {
+	t.Helper()
+	got := g.Output()
+	if update() {
+		if err := os.MkdirAll(filepath.Dir(g.path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(g.path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	b, err := ioutil.ReadFile(g.path)
+	if err != nil {
+		t.Fatalf("read golden file: %v (run with %s=1 to create it)", err, UpdateEnv)
+	}
+	if want := string(b); got != want {
+		t.Errorf("output differs from %s (run with %s=1 to accept):\n%s", g.path, UpdateEnv, lineDiff(want, got))
+	}
+}
+
+// lineDiff 逐行比较 want 和 got，列出不同的行，最多列出 20 行。
+func lineDiff(want, got string) string {
+	wl := strings.Split(want, "\n")
+	gl := strings.Split(got, "\n")
+	n := len(wl)
+	if len(gl) > n {
+		n = len(gl)
+	}
+	var buf bytes.Buffer
+	shown := 0
+	for i := 0; i < n && shown < 20; i++ {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w == g {
+			continue
+		}
+		shown++
+		if i < len(wl) {
+			fmt.Fprintf(&buf, "line %d: -%s\n", i+1, w)
+		}
+		if i < len(gl) {
+			fmt.Fprintf(&buf, "line %d: +%s\n", i+1, g)
+		}
+	}
+	if shown == 20 {
+		buf.WriteString("...\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// StatefulHandler 可选接口，状态保存在 Store 中的处理器实现它，Handoff 时状态交给新版本中同名的处理器。
+type StatefulHandler interface {
+	Handler
+	StateStore() Store
+	SetStateStore(store Store)
+}
+
+// Handoff 将 h 切换到新版本 next：先 Drain h，再把 h 中所有未处理完的源按原顺序移到 next 的待处理队列前面，
+// 已读取的位置和计数随源一起转移。next 在 Handoff 之后 Run 时从 h 停下的位置继续处理，
+// 守护模式下正在运行的 next 立即开始处理转移的源。
+// next 没有设置进度存储时使用 h 的进度存储，转移的源的位置保存到 next 的进度存储中；
+// h 中实现了 StatefulHandler 的命名处理器的 Store 交给 next 中同名的 StatefulHandler。
+// Drain 超时时不转移任何源，返回 ErrDrainTimeout；刷新处理器或保存位置失败时仍然转移，并返回该错误。
+func (h *Handlers) Handoff(next *Handlers, timeout time.Duration) error {
+	_, err := h.Drain(timeout)
+	if err == ErrDrainTimeout {
+		return err
+	}
+	h.moveStates(next)
+	if mErr := h.moveSrcs(next); err == nil {
+		err = mErr
+	}
+	return err
+}
+
+// moveStates 把 h 中命名处理器的状态交给 next 中同名的处理器。
+func (h *Handlers) moveStates(next *Handlers) {
+	h.chainMu.Lock()
+	stores := make(map[string]Store)
+	for name, e := range h.names {
+		if sh, ok := e.Value.(StatefulHandler); ok && sh.StateStore() != nil {
+			stores[name] = sh.StateStore()
+		}
+	}
+	h.chainMu.Unlock()
+	next.chainMu.Lock()
+	defer next.chainMu.Unlock()
+	for name, e := range next.names {
+		if sh, ok := e.Value.(StatefulHandler); ok && stores[name] != nil {
+			sh.SetStateStore(stores[name])
+		}
+	}
+}
+
+// moveSrcs 把 h 的待处理源移到 next 的待处理队列前面，并唤醒 next。
+func (h *Handlers) moveSrcs(next *Handlers) error {
+	if h.todoSrc == nil {
+		return nil
+	}
+	h.todoSrc.Lock()
+	var ents []*srcEntry
+	for e := h.todoSrc.Front(); e != nil; e = e.Next() {
+		ents = append(ents, e.Value.(*srcEntry))
+	}
+	h.todoSrc.Init()
+	h.todoSrc.Unlock()
+	if len(ents) == 0 {
+		return nil
+	}
+	err := h.moveCheckpoints(next, ents)
+
+	if next.todoSrc == nil {
+		next.Lock()
+		if next.todoSrc == nil {
+			next.todoSrc = newSafeList()
+		}
+		next.Unlock()
+	}
+	next.todoSrc.Lock()
+	for i := len(ents) - 1; i >= 0; i-- {
+		next.todoSrc.PushFront(ents[i])
+	}
+	next.todoSrc.Unlock()
+	next.wakeSrc()
+	return err
+}
+
+// moveCheckpoints next 没有设置进度存储时使用 h 的进度存储，并把 ents 的位置保存到 next 的进度存储中：
+// 已经开始处理的源保存当前位置，尚未开始的源复制 h 中保存的位置。
+func (h *Handlers) moveCheckpoints(next *Handlers, ents []*srcEntry) error {
+	h.RLock()
+	store, every := h.ckpt, h.ckptEvery
+	h.RUnlock()
+	next.Lock()
+	if next.ckpt == nil {
+		next.ckpt, next.ckptEvery = store, every
+	}
+	dst := next.ckpt
+	next.Unlock()
+	if dst == nil {
+		return nil
+	}
+	errBuf := bytes.Buffer{}
+	for _, ent := range ents {
+		p, ok := ent.src.(Positioner)
+		if !ok {
+			continue
+		}
+		var pos []byte
+		var err error
+		switch {
+		case atomic.LoadInt32(&ent.opened) == 1:
+			pos, err = p.Position()
+		case store != nil:
+			pos, err = store.Load(p.CheckpointKey())
+		}
+		if err == nil && pos != nil {
+			err = dst.Save(p.CheckpointKey(), pos)
+		}
+		if err != nil {
+			if errBuf.Len() > 0 {
+				errBuf.WriteString("; ")
+			}
+			errBuf.WriteString(err.Error())
+		}
+	}
+	if errBuf.Len() > 0 {
+		return errors.New(errBuf.String())
+	}
+	return nil
+}
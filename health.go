@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+)
+
+// HealthChecker 可选接口，数据源和处理器实现它以报告自身是否可用。
+type HealthChecker interface {
+	// Health 检查是否可用，返回 nil 表示健康。
+	Health() error
+}
+
+// Health 检查所有待处理源和处理器的健康状态，汇总所有错误。
+func (h *Handlers) Health() error {
+	errBuf := bytes.Buffer{}
+	check := func(v interface{}) {
+		if ent, ok := v.(*srcEntry); ok {
+			v = ent.src
+		}
+		hc, ok := v.(HealthChecker)
+		if !ok {
+			return
+		}
+		if err := hc.Health(); err != nil {
+			if errBuf.Len() > 0 {
+				errBuf.WriteString("; ")
+			}
+			errBuf.WriteString(err.Error())
+		}
+	}
+	for _, l := range []*safeList{h.todoSrc, h.handlers} {
+		if l == nil {
+			continue
+		}
+		l.RLock()
+		for e := l.Front(); e != nil; e = e.Next() {
+			check(e.Value)
+		}
+		l.RUnlock()
+	}
+	if errBuf.Len() > 0 {
+		return errors.New(errBuf.String())
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sliceSrc 依次返回 items 的数据源。
+type sliceSrc struct {
+	items []interface{}
+	i     int
+}
+
+func (s *sliceSrc) Next() (interface{}, error) {
+	if s.i >= len(s.items) {
+		return nil, io.EOF
+	}
+	s.i++
+	return s.items[s.i-1], nil
+}
+
+func newSlice(v ...interface{}) *sliceSrc { return &sliceSrc{items: v} }
+
+// chanSrc 从 channel 读取数据的源，channel 关闭时结束。
+type chanSrc struct{ c chan interface{} }
+
+func (s *chanSrc) Next() (interface{}, error) {
+	v, ok := <-s.c
+	if !ok {
+		return nil, io.EOF
+	}
+	return v, nil
+}
+
+// nopRejecter 丢弃处理失败的数据。
+type nopRejecter struct{}
+
+func (nopRejecter) Reject(Source, int64, interface{}, error) error { return nil }
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSourceIdle 数据源超过空闲时间没有产生数据。
+var ErrSourceIdle = errors.New("source idle timeout")
+
+// IdleTimeoutSource 为流式数据源增加空闲超时检测，
+// 底层 Next 超过指定时间没有返回数据时调用 OnIdle，并且 Health 返回 ErrSourceIdle。
+// 连接断开等错误仍然由底层源的 Next 和 Health 报告，以此区分“没有数据”和“连接异常”。
+type IdleTimeoutSource struct {
+	src     Source
+	timeout time.Duration
+	onIdle  func(src Source, idle time.Duration)
+	idle    int32 // 为 1 时表示当前处于空闲超时状态
+
+	mu  sync.Mutex
+	seq uint64 // 每次 Next 返回时加一，用于识别已经过期的定时器
+}
+
+// NewIdleTimeoutSrc 新建带空闲超时检测的数据源，onIdle 可以为 nil，在单独的 goroutine 中调用。
+func NewIdleTimeoutSrc(src Source, timeout time.Duration, onIdle func(src Source, idle time.Duration)) *IdleTimeoutSource {
+	return &IdleTimeoutSource{
+		src:     src,
+		timeout: timeout,
+		onIdle:  onIdle,
+	}
+}
+
+// Next 实现 Source 接口。
+func (its *IdleTimeoutSource) Next() (data interface{}, err error) {
+	its.mu.Lock()
+	seq := its.seq
+	its.mu.Unlock()
+	// Stop 返回时回调可能已经开始执行，回调在锁内检查 seq，Next 返回后不会再标记为空闲。
+	timer := time.AfterFunc(its.timeout, func() {
+		its.mu.Lock()
+		if its.seq != seq {
+			its.mu.Unlock()
+			return
+		}
+		atomic.StoreInt32(&its.idle, 1)
+		its.mu.Unlock()
+		if its.onIdle != nil {
+			its.onIdle(its.src, its.timeout)
+		}
+	})
+	data, err = its.src.Next()
+	timer.Stop()
+	its.mu.Lock()
+	its.seq++
+	atomic.StoreInt32(&its.idle, 0)
+	its.mu.Unlock()
+	return data, err
+}
+
+// Idle 返回当前是否处于空闲超时状态。
+func (its *IdleTimeoutSource) Idle() bool {
+	return atomic.LoadInt32(&its.idle) == 1
+}
+
+// Health 实现 HealthChecker 接口。
+func (its *IdleTimeoutSource) Health() error {
+	if hc, ok := its.src.(HealthChecker); ok {
+		if err := hc.Health(); err != nil {
+			return err
+		}
+	}
+	if its.Idle() {
+		return ErrSourceIdle
+	}
+	return nil
+}
+
+// Close 关闭底层数据源。
+func (its *IdleTimeoutSource) Close() error {
+	if c, ok := its.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileMark 单个文件的高水位记录。
+type FileMark struct {
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`   // 记录时的文件大小
+	Offset  int64     `json:"offset"` // 已经处理到的位置
+}
+
+// IncrementalFileSrc 增量多文件源。
+// 根据上次运行记录的高水位，只处理新文件和已有文件追加的部分，
+// 处理完成后调用 Commit 保存新的高水位，使用 AddDurableSink 时在输出确认写入后自动调用。
+type IncrementalFileSrc struct {
+	*MultiFileSrc
+	st *incrementalState
+}
+
+// incrementalState Split 拆分出的分片共享的高水位。
+type incrementalState struct {
+	mu        sync.Mutex
+	stateFile string
+	marks     map[string]FileMark
+	modTimes  map[string]time.Time // 本次打开时各文件的修改时间
+}
+
+// NewIncrementalFileSrc 创建增量多文件源，filesPattern 的意义和 filepath.Glob 相同，
+// stateFile 保存高水位，不存在时处理所有文件。
+func NewIncrementalFileSrc(filesPattern, stateFile string) (*IncrementalFileSrc, error) {
+	marks, err := loadFileMarks(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filesPattern)
+	if err != nil {
+		return nil, err
+	}
+	ifs := &IncrementalFileSrc{
+		MultiFileSrc: &MultiFileSrc{pattern: filesPattern},
+		st: &incrementalState{
+			stateFile: stateFile,
+			marks:     marks,
+			modTimes:  make(map[string]time.Time),
+		},
+	}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			ifs.Close()
+			return nil, err
+		}
+		var offset int64
+		if mark, ok := marks[file]; ok {
+			if info.Size() == mark.Offset && !info.ModTime().After(mark.ModTime) {
+				continue // 没有新数据
+			}
+			if info.Size() > mark.Offset {
+				offset = mark.Offset // 只处理追加的部分
+			}
+			// 文件变小说明被截断或替换，从头处理。
+		}
+		src, err := newFileSrcAt(file, offset)
+		if err != nil {
+			ifs.Close()
+			return nil, err
+		}
+		ifs.src = append(ifs.src, src)
+		ifs.st.modTimes[file] = info.ModTime()
+	}
+	return ifs, nil
+}
+
+func loadFileMarks(stateFile string) (map[string]FileMark, error) {
+	marks := make(map[string]FileMark)
+	b, err := ioutil.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return marks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &marks); err != nil {
+		return nil, err
+	}
+	return marks, nil
+}
+
+// Marks 返回当前的高水位，包含本次已经读取的部分。
+func (ifs *IncrementalFileSrc) Marks() map[string]FileMark {
+	ifs.st.mu.Lock()
+	defer ifs.st.mu.Unlock()
+	marks := make(map[string]FileMark, len(ifs.st.marks))
+	for k, v := range ifs.st.marks {
+		marks[k] = v
+	}
+	for _, src := range ifs.src {
+		marks[src.path] = ifs.st.markOf(src)
+	}
+	return marks
+}
+
+// markOf 返回 src 当前的高水位，调用方需持有 st.mu。
+func (st *incrementalState) markOf(src *FileSource) FileMark {
+	return FileMark{
+		ModTime: st.modTimes[src.path],
+		Size:    src.offset,
+		Offset:  src.offset,
+	}
+}
+
+// Commit 保存已经读取到的位置，下次运行从这里继续。Split 拆分出的分片各自提交自己的文件。
+func (ifs *IncrementalFileSrc) Commit() error {
+	ifs.st.mu.Lock()
+	defer ifs.st.mu.Unlock()
+	for _, src := range ifs.src {
+		ifs.st.marks[src.path] = ifs.st.markOf(src)
+	}
+	b, err := json.MarshalIndent(ifs.st.marks, "", "  ")
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免写到一半时中断导致状态文件损坏。
+	tmp := ifs.st.stateFile + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ifs.st.stateFile)
+}
+
+// Split 实现 SplittableSource 接口，同 MultiFileSrc.Split，分片仍然是共享高水位的 IncrementalFileSrc。
+func (ifs *IncrementalFileSrc) Split(n int) []Source {
+	parts := ifs.MultiFileSrc.Split(n)
+	if len(parts) == 1 && parts[0] == Source(ifs.MultiFileSrc) {
+		return []Source{ifs}
+	}
+	for i, part := range parts {
+		parts[i] = &IncrementalFileSrc{MultiFileSrc: part.(*MultiFileSrc), st: ifs.st}
+	}
+	return parts
+}
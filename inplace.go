@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// InPlaceSink 原地改写文件的输出：数据写入同目录的临时文件，Run 成功后（两阶段提交的 Commit）
+// 用临时文件原子地替换原文件，并保留原文件的权限；Run 失败时删除临时文件，原文件保持不变。
+// Backup 不为空时把原文件重命名为 path+Backup 保留。和 TxFileSink 不同，没有输出任何数据时原文件会被清空。
+type InPlaceSink struct {
+	*TxFileSink
+	// Backup 原文件备份的后缀，例如 ".bak"，为空时不保留原文件。
+	Backup string
+}
+
+// NewInPlaceSink 新建原地改写 path 的输出，backup 为原文件备份的后缀。
+func NewInPlaceSink(path, backup string) *InPlaceSink {
+	return &InPlaceSink{TxFileSink: NewTxFileSink(path), Backup: backup}
+}
+
+// Prepare 实现 TxSink 接口。
+func (is *InPlaceSink) Prepare() error {
+	is.mu.Lock()
+	err := is.ensure()
+	is.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return is.TxFileSink.Prepare()
+}
+
+// Commit 实现 TxSink 接口。
+func (is *InPlaceSink) Commit() error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if err := is.ensure(); err != nil {
+		return err
+	}
+	tmp := is.file.Name()
+	err := is.ws.Flush()
+	if cerr := is.file.Close(); err == nil {
+		err = cerr
+	}
+	is.file, is.ws = nil, nil
+	if err == nil {
+		err = is.replace(tmp)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// replace 用 tmp 替换原文件。调用方需持有 is.mu。
+func (is *InPlaceSink) replace(tmp string) error {
+	info, err := os.Stat(is.path)
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if is.Backup == "" {
+		return os.Rename(tmp, is.path)
+	}
+	bak := is.path + is.Backup
+	if err = os.Rename(is.path, bak); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, is.path); err != nil {
+		os.Rename(bak, is.path) // 恢复原文件
+		return err
+	}
+	return nil
+}
+
+// RewriteFiles 原地改写匹配 pattern（同 filepath.Glob）的所有文件：每个文件按行读取，依次交给 handlers 处理，
+// 处理链最后输出的数据替换文件原来的内容，每个文件单独运行，失败的文件保持不变。
+// backup 同 InPlaceSink.Backup。遇到失败时停止，返回已经改写的文件和带文件名的错误。
+func RewriteFiles(pattern, backup string, handlers ...Handler) (rewritten []string, err error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		src, err := NewFileSrc(file)
+		if err != nil {
+			return rewritten, err
+		}
+		h := &Handlers{ErrCheck: StopOnError}
+		h.AddSrc(src)
+		for _, handler := range handlers {
+			h.AddHandler(handler)
+		}
+		h.AddSink(NewInPlaceSink(file, backup))
+		err = h.Run()
+		src.Close()
+		if err != nil {
+			return rewritten, fmt.Errorf("rewrite %s: %v", file, err)
+		}
+		rewritten = append(rewritten, file)
+	}
+	return rewritten, nil
+}
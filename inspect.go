@@ -0,0 +1,31 @@
+package handlers
+
+// State 返回 Handlers 当前的状态。
+func (h *Handlers) State() int32 {
+	h.RLock()
+	defer h.RUnlock()
+	return h.state
+}
+
+// QueuedSources 返回尚未处理（或者被让出、Drain 放回）的源的快照。
+func (h *Handlers) QueuedSources() []Source {
+	return srcSnapshot(h.todoSrc)
+}
+
+// CompletedSources 返回已经处理完毕的源的快照。
+func (h *Handlers) CompletedSources() []Source {
+	return srcSnapshot(h.doneSrc)
+}
+
+func srcSnapshot(l *safeList) []Source {
+	if l == nil {
+		return nil
+	}
+	l.RLock()
+	defer l.RUnlock()
+	srcs := make([]Source, 0, l.Len())
+	for e := l.Front(); e != nil; e = e.Next() {
+		srcs = append(srcs, e.Value.(*srcEntry).src)
+	}
+	return srcs
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Compose 把多个中间件组合成一个，先列出的在最外层，和 net/http 中间件链的习惯相同。
+func Compose(mws ...Middleware) Middleware {
+	return func(next Handler) Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// Apply 用中间件包装单个处理器，先列出的在最外层，用于不通过 Use 而直接包装的场合。
+func Apply(h Handler, mws ...Middleware) Handler {
+	return Compose(mws...)(h)
+}
+
+// FuncMiddleware 把 func(next HandlerFunc) HandlerFunc 形式的中间件转换为 Middleware。
+func FuncMiddleware(f func(next HandlerFunc) HandlerFunc) Middleware {
+	return func(next Handler) Handler {
+		hf, ok := next.(HandlerFunc)
+		if !ok {
+			hf = next.Handle
+		}
+		return f(hf)
+	}
+}
+
+// PushHandler 把推送式的处理步骤转换为 Handler：stage 接收发送函数 emit，返回消费数据的函数，
+// 每条数据调用消费函数，期间通过 emit 发送的数据（0 条或多条）作为 Handle 的结果。
+// 处理器会被串行调用。
+func PushHandler(stage func(emit func(out interface{}) error) func(in interface{}) error) Handler {
+	var (
+		mu  sync.Mutex
+		buf Emit
+	)
+	consume := stage(func(out interface{}) error {
+		buf = append(buf, out)
+		return nil
+	})
+	return HandlerFunc(func(in interface{}) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		buf = nil
+		if err := consume(in); err != nil {
+			return nil, err
+		}
+		switch len(buf) {
+		case 0:
+			return None, nil
+		case 1:
+			return buf[0], nil
+		}
+		return buf, nil
+	})
+}
+
+// StreamFunc 基于 io.Reader 和 io.Writer 的流式处理步骤，例如已有的过滤、转换程序的主体。
+type StreamFunc func(r io.Reader, w io.Writer) error
+
+// StreamStage 把 StreamFunc 接入处理链：每个源的数据（string 或 []byte）依次通过 io.Pipe 写入 fn 的输入，
+// fn 的输出按行拆分（保留换行符）交给后面的处理器。fn 在源的第一条数据到来时启动，
+// 在源结束时关闭输入并等待 fn 返回，剩余的输出随源结束的控制标记一起输出。
+// 输出和输入不是一一对应的，Handle 返回到目前为止 fn 已经输出的行。要求源按顺序处理（默认）。
+type StreamStage struct {
+	fn StreamFunc
+
+	mu    sync.Mutex // 串行调用
+	in    *io.PipeWriter
+	done  chan struct{} // fn 和读取输出的 goroutine 都结束时关闭
+	outMu sync.Mutex
+	out   Emit  // 尚未交给后面的处理器的输出
+	err   error // fn 或读取输出时的错误
+}
+
+// NewStreamStage 新建流式处理步骤。
+func NewStreamStage(fn StreamFunc) *StreamStage {
+	return &StreamStage{fn: fn}
+}
+
+// start 启动 fn，调用方需持有 ss.mu。
+func (ss *StreamStage) start() {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	ss.in = inW
+	ss.done = make(chan struct{})
+	ss.out, ss.err = nil, nil
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		err := ss.fn(inR, outW)
+		if err == nil {
+			err = io.EOF
+		}
+		// fn 不再读取输入时让写入立即返回。
+		inR.CloseWithError(err)
+		if err == io.EOF {
+			err = nil
+		}
+		outW.CloseWithError(err)
+	}()
+	go func() {
+		defer wg.Done()
+		r := bufio.NewReader(outR)
+		for {
+			line, err := r.ReadString('\n')
+			ss.outMu.Lock()
+			if line != "" {
+				ss.out = append(ss.out, line)
+			}
+			if err != nil && err != io.EOF && ss.err == nil {
+				ss.err = err
+			}
+			ss.outMu.Unlock()
+			if err != nil {
+				outR.Close()
+				return
+			}
+		}
+	}()
+	go func(done chan struct{}) {
+		wg.Wait()
+		close(done)
+	}(ss.done)
+}
+
+// take 取出已经产生的输出。
+func (ss *StreamStage) take() (interface{}, error) {
+	ss.outMu.Lock()
+	defer ss.outMu.Unlock()
+	if ss.err != nil {
+		return nil, ss.err
+	}
+	out := ss.out
+	ss.out = nil
+	switch len(out) {
+	case 0:
+		return None, nil
+	case 1:
+		return out[0], nil
+	}
+	return out, nil
+}
+
+// Handle 实现 Handler 接口。
+func (ss *StreamStage) Handle(in interface{}) (interface{}, error) {
+	var b []byte
+	switch v := in.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return nil, errors.New("stream stage: want string or []byte")
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if len(b) == 0 {
+		// 没有需要写入的数据，只取出已经产生的输出。
+		if ss.in == nil {
+			return None, nil
+		}
+		return ss.take()
+	}
+	if ss.in == nil {
+		ss.start()
+	}
+	_, err := ss.in.Write(b)
+	if err == io.EOF {
+		err = nil // fn 提前结束（不再读取输入）时忽略之后的数据。
+	}
+	var out interface{}
+	if err == nil {
+		out, err = ss.take()
+	}
+	if err != nil {
+		// 出错时源被中止，不会收到源结束的标记，在这里结束 fn，下一个源重新开始。
+		ss.in.CloseWithError(err)
+		<-ss.done
+		ss.in = nil
+		return nil, err
+	}
+	return out, nil
+}
+
+// HandleMarker 实现 MarkerHandler 接口，源结束时关闭 fn 的输入，等待它返回并输出剩余的行。
+func (ss *StreamStage) HandleMarker(m Marker) (interface{}, error) {
+	if m.Kind != MarkerEndOfSource {
+		return nil, nil
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.in == nil {
+		return nil, nil
+	}
+	ss.in.Close()
+	<-ss.done
+	ss.in = nil
+	out, err := ss.take()
+	if err != nil || out == None {
+		return nil, err
+	}
+	return out, nil
+}
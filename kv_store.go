@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"container/list"
+	"encoding/gob"
+	"os"
+	"sync"
+	"time"
+)
+
+// Store 键值存储，供去重、限流、缓存等有状态的处理器共享。实现必须是并发安全的。
+type Store interface {
+	// Get 返回未过期的值。
+	Get(key string) (value interface{}, ok bool)
+	// Set 写入值，ttl <= 0 表示永不过期。
+	Set(key string, value interface{}, ttl time.Duration)
+	// Update 原子地读取并修改值，fn 返回新的值，ok 表示旧值是否存在。
+	Update(key string, ttl time.Duration, fn func(old interface{}, ok bool) interface{}) interface{}
+	// Delete 删除值。
+	Delete(key string)
+	// Len 返回值的数量，可能包含尚未清理的过期值。
+	Len() int
+}
+
+type memEntry struct {
+	key      string
+	value    interface{}
+	expireAt time.Time // 零值表示永不过期
+}
+
+// MemStore 内存键值存储，支持过期时间和容量上限，超过容量时淘汰最久未使用的值。
+type MemStore struct {
+	mu      sync.Mutex
+	items   map[string]*list.Element
+	lru     *list.List // 最近使用的在前面
+	maxSize int
+}
+
+// NewMemStore 新建内存键值存储，maxSize <= 0 表示不限制容量。
+func NewMemStore(maxSize int) *MemStore {
+	return &MemStore{
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+		maxSize: maxSize,
+	}
+}
+
+// get 调用方需持有 ms.mu。
+func (ms *MemStore) get(key string) (*memEntry, bool) {
+	ele, ok := ms.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := ele.Value.(*memEntry)
+	if !entry.expireAt.IsZero() && time.Now().After(entry.expireAt) {
+		ms.lru.Remove(ele)
+		delete(ms.items, key)
+		return nil, false
+	}
+	ms.lru.MoveToFront(ele)
+	return entry, true
+}
+
+// set 调用方需持有 ms.mu。
+func (ms *MemStore) set(key string, value interface{}, ttl time.Duration) {
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = time.Now().Add(ttl)
+	}
+	if ele, ok := ms.items[key]; ok {
+		entry := ele.Value.(*memEntry)
+		entry.value, entry.expireAt = value, expireAt
+		ms.lru.MoveToFront(ele)
+		return
+	}
+	ms.items[key] = ms.lru.PushFront(&memEntry{key: key, value: value, expireAt: expireAt})
+	if ms.maxSize > 0 && ms.lru.Len() > ms.maxSize {
+		ms.evictExpired()
+		for ms.lru.Len() > ms.maxSize {
+			ele := ms.lru.Back()
+			ms.lru.Remove(ele)
+			delete(ms.items, ele.Value.(*memEntry).key)
+		}
+	}
+}
+
+// Get 实现 Store 接口。
+func (ms *MemStore) Get(key string) (interface{}, bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	entry, ok := ms.get(key)
+	if !ok {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Set 实现 Store 接口。
+func (ms *MemStore) Set(key string, value interface{}, ttl time.Duration) {
+	ms.mu.Lock()
+	ms.set(key, value, ttl)
+	ms.mu.Unlock()
+}
+
+// Update 实现 Store 接口。
+func (ms *MemStore) Update(key string, ttl time.Duration, fn func(old interface{}, ok bool) interface{}) interface{} {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var old interface{}
+	entry, ok := ms.get(key)
+	if ok {
+		old = entry.value
+	}
+	value := fn(old, ok)
+	ms.set(key, value, ttl)
+	return value
+}
+
+// Delete 实现 Store 接口。
+func (ms *MemStore) Delete(key string) {
+	ms.mu.Lock()
+	if ele, ok := ms.items[key]; ok {
+		ms.lru.Remove(ele)
+		delete(ms.items, key)
+	}
+	ms.mu.Unlock()
+}
+
+// Len 实现 Store 接口。
+func (ms *MemStore) Len() int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.lru.Len()
+}
+
+// EvictExpired 清理所有过期的值。
+func (ms *MemStore) EvictExpired() {
+	ms.mu.Lock()
+	ms.evictExpired()
+	ms.mu.Unlock()
+}
+
+// evictExpired 调用方需持有 ms.mu。
+func (ms *MemStore) evictExpired() {
+	now := time.Now()
+	for ele := ms.lru.Front(); ele != nil; {
+		next := ele.Next()
+		entry := ele.Value.(*memEntry)
+		if !entry.expireAt.IsZero() && now.After(entry.expireAt) {
+			ms.lru.Remove(ele)
+			delete(ms.items, entry.key)
+		}
+		ele = next
+	}
+}
+
+// memSnapshot 持久化时保存的值。
+type memSnapshot struct {
+	Key      string
+	Value    interface{}
+	ExpireAt time.Time
+}
+
+// SaveFile 把未过期的值用 gob 编码保存到文件，自定义类型的值需要先调用 gob.Register。
+func (ms *MemStore) SaveFile(path string) error {
+	ms.mu.Lock()
+	ms.evictExpired()
+	snap := make([]memSnapshot, 0, ms.lru.Len())
+	// 从最久未使用的开始保存，加载时按顺序写入可以恢复使用顺序。
+	for ele := ms.lru.Back(); ele != nil; ele = ele.Prev() {
+		entry := ele.Value.(*memEntry)
+		snap = append(snap, memSnapshot{Key: entry.key, Value: entry.value, ExpireAt: entry.expireAt})
+	}
+	ms.mu.Unlock()
+
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err = gob.NewEncoder(file).Encode(snap); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadFile 加载 SaveFile 保存的值，已经过期的值会被忽略，文件不存在时不做任何事。
+func (ms *MemStore) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var snap []memSnapshot
+	if err = gob.NewDecoder(file).Decode(&snap); err != nil {
+		return err
+	}
+	now := time.Now()
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, s := range snap {
+		if s.ExpireAt.IsZero() {
+			ms.set(s.Key, s.Value, 0)
+		} else if ttl := s.ExpireAt.Sub(now); ttl > 0 {
+			ms.set(s.Key, s.Value, ttl)
+		}
+	}
+	return nil
+}
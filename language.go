@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// LangUnknown 无法判断语言时的结果。
+const LangUnknown = "und"
+
+// 按文字判断的语言。
+var scriptLangs = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"}, {unicode.Katakana, "ja"}, // 先于汉字判断，日文中通常混有假名
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+// 拉丁字母语言的常用词。
+var latinLangWords = map[string]map[string]bool{
+	"en": stopwordSet("the and of to is in that it was for on are with as be this have not you at by from"),
+	"es": stopwordSet("el la de que y en los se del las un por con no una su para es al lo como más"),
+	"fr": stopwordSet("le la de et les des est un une du en que pas pour qui dans sur au avec ce il je"),
+	"de": stopwordSet("der die und in den von zu das mit sich des auf für ist im dem nicht ein eine als auch"),
+	"it": stopwordSet("il di che la e un per non in sono mi ho lo ma si una con gli del della le"),
+	"pt": stopwordSet("de que e o da do em um para é com não uma os no se na por mais as dos"),
+	"nl": stopwordSet("de het een en van ik te dat die in is niet zijn op aan met voor er maar"),
+}
+
+// DetectLanguage 判断文本的语言，返回 ISO 639-1 代码，无法判断时返回 LangUnknown。
+// 非拉丁文字按字符所属的文字判断；拉丁字母文本按常用词出现的次数在 en es fr de it pt nl 中选择。
+// 只适用于粗略的分流，短文本的结果可能不准确。
+func DetectLanguage(text string) string {
+	counts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, sl := range scriptLangs {
+			if unicode.Is(sl.table, r) {
+				counts[sl.lang]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return LangUnknown
+	}
+	if counts["ja"] > 0 && counts["zh"] > 0 {
+		counts["ja"] += counts["zh"]
+		delete(counts, "zh")
+	}
+	best, bestN := "", 0
+	for _, sl := range scriptLangs {
+		if n := counts[sl.lang]; n > bestN {
+			best, bestN = sl.lang, n
+		}
+	}
+	if bestN*2 >= letters { // 至少一半的字母属于该文字
+		return best
+	}
+
+	words := Tokenize(text)
+	best, bestN = LangUnknown, 0
+	for _, lang := range []string{"en", "es", "fr", "de", "it", "pt", "nl"} {
+		set := latinLangWords[lang]
+		n := 0
+		for _, w := range words {
+			if set[w] {
+				n++
+			}
+		}
+		if n > bestN {
+			best, bestN = lang, n
+		}
+	}
+	return best
+}
+
+// LanguageDetector 判断文本的语言。输入为 string 时直接判断，
+// 否则判断 map[string]interface{} 中名为 Field 的字段并把结果写入 Output 字段。
+// 设置 Routes 后按语言把数据发送到旁路输出。
+type LanguageDetector struct {
+	field string
+	emit  SideEmitter
+
+	// Output 结果写入的字段，默认为 "lang"。输入为 string 时不写入。
+	Output string
+	// Routes 语言到旁路输出名称的映射，"*" 匹配其他所有语言。匹配的数据发送到旁路输出，不再交给后面的处理器。
+	Routes map[string]string
+}
+
+// NewLanguageDetector 新建语言检测处理器，field 为空时输入应为 string。
+func NewLanguageDetector(field string) *LanguageDetector {
+	return &LanguageDetector{field: field, Output: "lang"}
+}
+
+// SetSideEmitter 实现 SideOutputHandler 接口。
+func (ld *LanguageDetector) SetSideEmitter(emit SideEmitter) {
+	ld.emit = emit
+}
+
+// Handle 实现 Handler 接口。
+func (ld *LanguageDetector) Handle(in interface{}) (interface{}, error) {
+	var text string
+	m, isMap := in.(map[string]interface{})
+	switch {
+	case ld.field == "":
+		s, ok := in.(string)
+		if !ok {
+			return nil, fmt.Errorf("language detector: expects string, got %T", in)
+		}
+		text = s
+	case isMap:
+		v, _ := fieldValue(m, ld.field)
+		s, ok := v.(string)
+		if !ok && v != nil {
+			return nil, fmt.Errorf("language detector: field %q is %T, want string", ld.field, v)
+		}
+		text = s
+	default:
+		return nil, fmt.Errorf("language detector: expects map[string]interface{}, got %T", in)
+	}
+	lang := DetectLanguage(text)
+	out := in
+	if isMap && ld.field != "" {
+		output := ld.Output
+		if output == "" {
+			output = "lang"
+		}
+		out = setField(m, strings.Split(output, "."), lang)
+	}
+	name, ok := ld.Routes[lang]
+	if !ok {
+		name, ok = ld.Routes["*"]
+	}
+	if !ok {
+		return out, nil
+	}
+	if ld.emit == nil {
+		return nil, fmt.Errorf("language detector: side output %q not bound", name)
+	}
+	if err := ld.emit(name, out); err != nil {
+		return nil, err
+	}
+	return None, nil
+}
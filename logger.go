@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+)
+
+// Logger 结构化日志接口，kv 为交替出现的键和值，*slog.Logger 满足该接口。
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+// loggerBox atomic.Value 中不能存放不同类型的值，也不能存放 nil。
+type loggerBox struct{ l Logger }
+
+// SetLogger 设置 Run 的日志：Run 的开始和结束、源的开始和结束（包括读取的数据条数）记为 Info，
+// 处理器失败和源出错记为 Error，丢弃数据记为 Debug。l 为 nil 时关闭日志。
+func (h *Handlers) SetLogger(l Logger) {
+	h.logger.Store(loggerBox{l})
+}
+
+func (h *Handlers) loadLogger() Logger {
+	box, _ := h.logger.Load().(loggerBox)
+	return box.l
+}
+
+// logEvent 把事件写入日志。
+func logEvent(l Logger, ev Event) {
+	switch ev.Kind {
+	case EventRunStart:
+		l.Info("run started")
+	case EventRunStop:
+		if ev.Err != nil {
+			l.Error("run failed", "error", ev.Err)
+		} else {
+			l.Info("run finished")
+		}
+	case EventSourceOpened:
+		l.Info("source started", "source", sourceLabel(ev.Source))
+	case EventSourceExhausted:
+		if ev.Err != nil && ev.Err != io.EOF {
+			l.Error("source failed", "source", sourceLabel(ev.Source), "items", ev.Items, "error", ev.Err)
+		} else {
+			l.Info("source finished", "source", sourceLabel(ev.Source), "items", ev.Items)
+		}
+	case EventHandlerError:
+		l.Error("handler failed", "handler", ev.Handler, "item", ev.Item, "error", ev.Err)
+	case EventItemDropped:
+		l.Debug("item dropped", "handler", ev.Handler, "item", ev.Item)
+	}
+}
+
+// sourceLabel 日志中源的名称，实现了 DescribedSource 的使用 Name，否则使用类型。
+func sourceLabel(src Source) string {
+	if ds, ok := src.(DescribedSource); ok {
+		return ds.Name()
+	}
+	return fmt.Sprintf("%T", src)
+}
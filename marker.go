@@ -0,0 +1,96 @@
+package handlers
+
+import "time"
+
+// MarkerKind 控制标记的类型。
+type MarkerKind int
+
+// 控制标记的类型
+const (
+	MarkerHeartbeat   MarkerKind = iota // 心跳，按 SetHeartbeat 设置的间隔注入
+	MarkerEndOfWindow                   // 窗口结束，由调用方通过 InjectMarker 注入
+	MarkerEndOfSource                   // 一个源的数据已经读完
+)
+
+// Marker 注入到数据流中的控制标记，只交给实现了 MarkerHandler 的处理器，
+// 其他处理器不会看到它。
+type Marker struct {
+	Kind   MarkerKind
+	Source Source // 注入标记时正在处理的源
+	Time   time.Time
+}
+
+// MarkerHandler 可选接口，有状态的处理器实现它以观察控制标记，例如在源结束时输出缓存的数据。
+type MarkerHandler interface {
+	// HandleMarker 处理控制标记，out 不为 nil 时作为普通数据交给后面的处理器。
+	HandleMarker(m Marker) (out interface{}, err error)
+}
+
+// SetHeartbeat 设置在数据之间注入心跳标记的间隔，d <= 0 表示不注入（默认）。
+func (h *Handlers) SetHeartbeat(d time.Duration) {
+	h.Lock()
+	h.heartbeat = d
+	h.Unlock()
+}
+
+// InjectMarker 注入一个控制标记，在处理下一条数据之前交给处理链。可以在其他 goroutine 中调用。
+func (h *Handlers) InjectMarker(m Marker) {
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+	h.markerMu.Lock()
+	h.markers = append(h.markers, m)
+	h.markerMu.Unlock()
+}
+
+// handlePendingMarkers 处理所有等待注入的控制标记。
+// fl 为正在处理的源的 flight。
+func (h *Handlers) handlePendingMarkers(fl *flight) error {
+	h.markerMu.Lock()
+	markers := h.markers
+	h.markers = nil
+	h.markerMu.Unlock()
+	for _, m := range markers {
+		if err := h.handleMarker(fl, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markerTarget 返回接收控制标记的处理器：orig 没有实现 MarkerHandler 时 ok 为 false；
+// 中间件包装后的 wrapped 也实现了 MarkerHandler 时交给 wrapped，使中间件能看到控制标记，否则交给 orig。
+func markerTarget(orig, wrapped Handler) (hd Handler, mh MarkerHandler, ok bool) {
+	if _, ok = orig.(MarkerHandler); !ok {
+		return nil, nil, false
+	}
+	if mh, ok = wrapped.(MarkerHandler); ok {
+		return wrapped, mh, true
+	}
+	return orig, orig.(MarkerHandler), true
+}
+
+// handleMarker 等待正在处理的源的异步处理完成后，把控制标记依次交给处理链中实现了 MarkerHandler 的处理器，
+// 处理器因标记输出的数据交给其后的处理器处理。
+func (h *Handlers) handleMarker(fl *flight, m Marker) error {
+	// 先等待异步处理完成，保证这个源在标记之前的数据都已经处理过。
+	if err := fl.wait(); err != nil {
+		return err
+	}
+	for e := h.chainFront(); e != nil; e = e.Next() {
+		hd, mh, ok := markerTarget(e.Value.(Handler), h.handlerAt(e))
+		if !ok {
+			continue
+		}
+		out, err := callMarker(hd, mh, m)
+		if err != nil {
+			return err
+		}
+		if out != nil {
+			if err = h.emitFrom(fl, e.Next(), out); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	_ "image/gif" // 注册图片解码器，用于 image.DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileMetadata 二进制文件的元数据。
+type FileMetadata struct {
+	Path    string            `json:"path"`
+	Size    int64             `json:"size"`
+	ModTime time.Time         `json:"mod_time"`
+	MIME    string            `json:"mime"`             // 按内容判断的 MIME 类型
+	Format  string            `json:"format,omitempty"` // 图片格式，例如 png、jpeg、gif
+	Width   int               `json:"width,omitempty"`  // 图片宽度（像素）
+	Height  int               `json:"height,omitempty"` // 图片高度（像素）
+	Pages   int               `json:"pages,omitempty"`  // PDF 的页数
+	EXIF    map[string]string `json:"exif,omitempty"`   // JPEG 中的部分 EXIF 信息：Make、Model、DateTime、Orientation
+	Err     string            `json:"error,omitempty"`  // 由 MetadataSource 产生时，读取文件失败的原因
+}
+
+// MaxPDFScan 统计 PDF 页数时最多读取的字节数。
+var MaxPDFScan int64 = 64 << 20
+
+// ExtractMetadata 读取文件的元数据。无法识别的文件只返回大小、修改时间和 MIME 类型。
+func ExtractMetadata(path string) (*FileMetadata, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	md := &FileMetadata{Path: path, Size: info.Size(), ModTime: info.ModTime()}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+	md.MIME = http.DetectContentType(head)
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasPrefix(md.MIME, "image/"):
+		if cfg, format, err := image.DecodeConfig(bufio.NewReader(file)); err == nil {
+			md.Format, md.Width, md.Height = format, cfg.Width, cfg.Height
+		}
+		if md.Format == "jpeg" {
+			if _, err = file.Seek(0, io.SeekStart); err == nil {
+				md.EXIF = readJPEGExif(bufio.NewReader(file))
+			}
+		}
+	case md.MIME == "application/pdf":
+		md.Pages = countPDFPages(io.LimitReader(file, MaxPDFScan))
+	}
+	return md, nil
+}
+
+var pdfPageRe = regexp.MustCompile(`/Type\s*/Page[^s]`)
+
+// countPDFPages 统计 PDF 中的页面对象数，不解析压缩的对象流，结果可能偏少。
+func countPDFPages(r io.Reader) int {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return 0
+	}
+	return len(pdfPageRe.FindAllIndex(b, -1))
+}
+
+// EXIF 中读取的 IFD0 标签。
+var exifTags = map[uint16]string{
+	0x010f: "Make",
+	0x0110: "Model",
+	0x0112: "Orientation",
+	0x0132: "DateTime",
+}
+
+// readJPEGExif 从 JPEG 的 APP1 段中读取 IFD0 中的部分标签，没有 EXIF 时返回 nil。
+func readJPEGExif(r *bufio.Reader) map[string]string {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xff, 0xd8} {
+		return nil
+	}
+	for {
+		var seg [4]byte
+		if _, err := io.ReadFull(r, seg[:]); err != nil || seg[0] != 0xff {
+			return nil
+		}
+		size := int(binary.BigEndian.Uint16(seg[2:])) - 2
+		if size < 0 || seg[1] == 0xda { // 图像数据开始，之后不再有元数据
+			return nil
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil
+		}
+		if seg[1] == 0xe1 && bytes.HasPrefix(data, []byte("Exif\x00\x00")) {
+			tags, _ := parseTIFF(data[6:])
+			return tags
+		}
+	}
+}
+
+// parseTIFF 解析 TIFF 结构中的 IFD0。
+func parseTIFF(b []byte) (map[string]string, error) {
+	if len(b) < 8 {
+		return nil, errors.New("short tiff header")
+	}
+	var bo binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return nil, errors.New("bad tiff byte order")
+	}
+	off := int(bo.Uint32(b[4:]))
+	if off+2 > len(b) {
+		return nil, errors.New("bad ifd offset")
+	}
+	n := int(bo.Uint16(b[off:]))
+	tags := make(map[string]string)
+	for i := 0; i < n; i++ {
+		e := off + 2 + i*12
+		if e+12 > len(b) {
+			break
+		}
+		name, ok := exifTags[bo.Uint16(b[e:])]
+		if !ok {
+			continue
+		}
+		typ, count := bo.Uint16(b[e+2:]), int(bo.Uint32(b[e+4:]))
+		switch typ {
+		case 2: // ASCII
+			val := b[e+8 : e+12]
+			if count > 4 {
+				p := int(bo.Uint32(b[e+8:]))
+				if p < 0 || p+count > len(b) {
+					continue
+				}
+				val = b[p : p+count]
+			} else {
+				val = val[:count]
+			}
+			tags[name] = strings.TrimRight(string(val), "\x00 ")
+		case 3: // SHORT
+			tags[name] = strconv.Itoa(int(bo.Uint16(b[e+8:])))
+		}
+	}
+	return tags, nil
+}
+
+// MetadataSource 二进制文件源，每个文件产生一条 *FileMetadata，而不是按行读取。
+// 读取某个文件失败时该条数据的 Err 不为空，继续处理下一个文件。
+type MetadataSource struct {
+	pattern string
+	files   []string
+	index   int
+}
+
+// NewMetadataSrc 新建二进制文件源，filesPattern 的格式同 filepath.Glob。
+func NewMetadataSrc(filesPattern string) (*MetadataSource, error) {
+	files, err := filepath.Glob(filesPattern)
+	if err != nil {
+		return nil, err
+	}
+	return &MetadataSource{pattern: filesPattern, files: files}, nil
+}
+
+// Next 实现 Source 接口。
+func (ms *MetadataSource) Next() (data interface{}, err error) {
+	if ms.index >= len(ms.files) {
+		return nil, io.EOF
+	}
+	path := ms.files[ms.index]
+	ms.index++
+	md, err := ExtractMetadata(path)
+	if err != nil {
+		return &FileMetadata{Path: path, Err: err.Error()}, nil
+	}
+	return md, nil
+}
+
+// Name 实现 DescribedSource 接口。
+func (ms *MetadataSource) Name() string { return ms.pattern }
+
+// Size 实现 DescribedSource 接口，返回 -1。
+func (ms *MetadataSource) Size() int64 { return -1 }
+
+// EstimatedItems 实现 DescribedSource 接口，返回文件数。
+func (ms *MetadataSource) EstimatedItems() int64 { return int64(len(ms.files)) }
+
+// Line 返回已经处理的文件数。
+func (ms *MetadataSource) Line() int64 { return int64(ms.index) }
+
+// MetadataHandler 返回把文件路径（string，例如 FileSource 读到的一行）转换为 *FileMetadata 的处理器。
+func MetadataHandler() Handler {
+	return HandlerFunc(func(in interface{}) (interface{}, error) {
+		path, ok := in.(string)
+		if !ok {
+			return in, nil
+		}
+		if path = strings.TrimSpace(path); path == "" {
+			return None, nil
+		}
+		return ExtractMetadata(path)
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import "container/list"
+
+// Middleware 包装处理器，用于给处理链中的每个处理器加上日志、指标、跟踪、重试等通用逻辑。
+type Middleware func(next Handler) Handler
+
+// Use 添加中间件，在下次 Run 时包装处理链中的每个处理器（异步处理器除外）。
+// 先添加的中间件在最外层，即 Use(a, b) 后调用顺序为 a、b、处理器。
+// 中间件包装后的处理器实现了 MarkerHandler 时，原处理器的控制标记交给它，由它转交原处理器；
+// 否则控制标记直接交给原处理器。Flush 等仍直接交给原处理器。
+func (h *Handlers) Use(mw ...Middleware) {
+	h.Lock()
+	h.middleware = append(h.middleware, mw...)
+	h.Unlock()
+}
+
+// applyMiddleware 在 Run 开始时用中间件包装处理链中的处理器。
+func (h *Handlers) applyMiddleware() {
+	h.RLock()
+	mws := h.middleware
+	h.RUnlock()
+	h.chainMu.Lock()
+	defer h.chainMu.Unlock()
+	var wrapped map[*list.Element]Handler
+	if len(mws) > 0 && h.handlers != nil {
+		wrapped = make(map[*list.Element]Handler)
+		h.handlers.RLock()
+		for e := h.handlers.Front(); e != nil; e = e.Next() {
+			if _, ok := e.Value.(*asyncStage); ok {
+				continue
+			}
+			v := e.Value.(Handler)
+			for i := len(mws) - 1; i >= 0; i-- {
+				v = mws[i](v)
+			}
+			wrapped[e] = v
+		}
+		h.handlers.RUnlock()
+	}
+	h.wrapped.Store(wrapped)
+}
+
+// remapWrapped 处理链被复制后，让副本中的处理器沿用原来的包装。
+// 原来的元素仍然保留，旧的处理链上正在处理的数据也能找到包装。调用方需持有 h.chainMu。
+func (h *Handlers) remapWrapped(elems map[*list.Element]*list.Element) {
+	old, _ := h.wrapped.Load().(map[*list.Element]Handler)
+	if len(old) == 0 {
+		return
+	}
+	wrapped := make(map[*list.Element]Handler, len(old)+len(elems))
+	for e, v := range old {
+		wrapped[e] = v
+	}
+	for oe, ne := range elems {
+		if v, ok := old[oe]; ok {
+			wrapped[ne] = v
+		}
+	}
+	h.wrapped.Store(wrapped)
+}
+
+// handlerAt 返回处理链中 e 处实际调用的处理器，即中间件包装后的处理器。
+func (h *Handlers) handlerAt(e *list.Element) Handler {
+	wrapped, _ := h.wrapped.Load().(map[*list.Element]Handler)
+	if v, ok := wrapped[e]; ok {
+		return v
+	}
+	return e.Value.(Handler)
+}
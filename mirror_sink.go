@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+)
+
+// MirrorStats MirrorSink 的统计信息。
+type MirrorStats struct {
+	Failovers int64 // 主输出不可用、切换到备用输出的次数
+	Replayed  int64 // 主输出恢复后补写的数据条数
+	Pending   int   // 等待补写到主输出的数据条数
+	Divergent int64 // 只写入了其中一个输出的数据条数（缓冲区溢出或镜像写入失败）
+}
+
+// MirrorSink 写入主输出，主输出出错时切换到备用输出，并把数据缓存在本地，
+// 每隔 RetryInterval 尝试把缓存的数据按顺序补写到主输出，全部补写成功后切换回主输出。
+// Mirror 为 true 时主输出正常的情况下也同时写入备用输出。
+type MirrorSink struct {
+	primary, secondary Sink
+
+	// Mirror 为 true 时每条数据都写入两个输出。
+	Mirror bool
+	// RetryInterval 主输出不可用时重试的间隔，默认为 1 秒。
+	RetryInterval time.Duration
+	// MaxBuffer 等待补写的数据条数上限，超过时丢弃最早的数据并计入 Divergent，<= 0 表示不限制。
+	MaxBuffer int
+	// OnFailover 切换到备用输出时调用，err 为主输出的错误。
+	OnFailover func(err error)
+	// OnRecover 切换回主输出时调用。
+	OnRecover func()
+
+	mu        sync.Mutex
+	down      bool
+	lastTry   time.Time
+	backlog   []interface{}
+	failovers int64
+	replayed  int64
+	divergent int64
+}
+
+// NewMirrorSink 新建带故障切换的输出。
+func NewMirrorSink(primary, secondary Sink) *MirrorSink {
+	return &MirrorSink{primary: primary, secondary: secondary}
+}
+
+// Write 实现 Sink 接口，只有两个输出都写入失败时才返回错误。
+func (ms *MirrorSink) Write(out interface{}) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.down {
+		ms.tryRecover(time.Now())
+	}
+	if !ms.down {
+		err := ms.primary.Write(out)
+		if err == nil {
+			if ms.Mirror && ms.secondary.Write(out) != nil {
+				ms.divergent++
+			}
+			return nil
+		}
+		ms.down, ms.lastTry = true, time.Now()
+		ms.failovers++
+		if ms.OnFailover != nil {
+			ms.OnFailover(err)
+		}
+	}
+	if err := ms.secondary.Write(out); err != nil {
+		return err
+	}
+	ms.backlog = append(ms.backlog, out)
+	if ms.MaxBuffer > 0 && len(ms.backlog) > ms.MaxBuffer {
+		ms.backlog = ms.backlog[1:]
+		ms.divergent++
+	}
+	return nil
+}
+
+// tryRecover 到了重试时间时把缓存的数据补写到主输出。调用方需持有 ms.mu。
+func (ms *MirrorSink) tryRecover(now time.Time) {
+	interval := ms.RetryInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if now.Sub(ms.lastTry) < interval {
+		return
+	}
+	ms.lastTry = now
+	if ms.replay() != nil {
+		return
+	}
+	ms.down = false
+	if ms.OnRecover != nil {
+		ms.OnRecover()
+	}
+}
+
+// replay 按顺序补写缓存的数据，遇到错误时停止。调用方需持有 ms.mu。
+func (ms *MirrorSink) replay() error {
+	for len(ms.backlog) > 0 {
+		if err := ms.primary.Write(ms.backlog[0]); err != nil {
+			return err
+		}
+		ms.backlog[0] = nil
+		ms.backlog = ms.backlog[1:]
+		ms.replayed++
+	}
+	return nil
+}
+
+// Stats 返回统计信息。
+func (ms *MirrorSink) Stats() MirrorStats {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return MirrorStats{Failovers: ms.failovers, Replayed: ms.replayed,
+		Pending: len(ms.backlog), Divergent: ms.divergent}
+}
+
+// Flush 实现 Flusher 接口，刷新两个输出。
+func (ms *MirrorSink) Flush() error {
+	var flushers []Flusher
+	for _, s := range []Sink{ms.primary, ms.secondary} {
+		if f, ok := s.(Flusher); ok {
+			flushers = append(flushers, f)
+		}
+	}
+	return flushAll(flushers)
+}
+
+// Close 实现 Sink 接口，关闭前最后尝试一次补写，仍有数据没有补写时返回错误。
+func (ms *MirrorSink) Close() error {
+	errBuf := bytes.Buffer{}
+	ms.mu.Lock()
+	if ms.down {
+		if err := ms.replay(); err != nil {
+			errBuf.WriteString("mirror sink: primary not caught up: " + err.Error())
+		}
+	}
+	ms.mu.Unlock()
+	for _, s := range []Sink{ms.primary, ms.secondary} {
+		if err := s.Close(); err != nil {
+			if errBuf.Len() > 0 {
+				errBuf.WriteString("; ")
+			}
+			errBuf.WriteString(err.Error())
+		}
+	}
+	if errBuf.Len() > 0 {
+		return errors.New(errBuf.String())
+	}
+	return nil
+}
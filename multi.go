@@ -0,0 +1,44 @@
+package handlers
+
+// MultiHandler 一条输入产生多条输出的处理器，每条输出依次交给后面的处理器，返回空切片时丢弃当前数据。
+type MultiHandler interface {
+	HandleMulti(in interface{}) ([]interface{}, error)
+}
+
+// MultiHandlerFunc function式MultiHandler.
+type MultiHandlerFunc func(in interface{}) ([]interface{}, error)
+
+// HandleMulti 实现MultiHandler接口。
+func (mf MultiHandlerFunc) HandleMulti(in interface{}) ([]interface{}, error) { return mf(in) }
+
+// EmitterFunc 通过回调产生输出的处理函数，每调用一次 emit 产生一条输出。
+type EmitterFunc func(in interface{}, emit func(out interface{})) error
+
+// FlatMap 把 MultiHandler 转换为 Handler，输出以 Emit 返回。
+func FlatMap(mh MultiHandler) Handler {
+	return multiHandler{mh}
+}
+
+type multiHandler struct{ mh MultiHandler }
+
+func (m multiHandler) Handle(in interface{}) (interface{}, error) {
+	outs, err := m.mh.HandleMulti(in)
+	if err != nil {
+		return nil, err
+	}
+	return Emit(outs), nil
+}
+
+// Handle 实现 Handler 接口，emit 的所有输出以 Emit 返回。
+func (ef EmitterFunc) Handle(in interface{}) (interface{}, error) {
+	var out Emit
+	if err := ef(in, func(v interface{}) { out = append(out, v) }); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AddMultiHandler 添加一条输入产生多条输出的处理器。
+func (h *Handlers) AddMultiHandler(mh MultiHandler) {
+	h.AddHandler(FlatMap(mh))
+}
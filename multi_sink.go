@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// MultiSink 按数据选择写入的输出，例如错误写入死信文件、指标写入 StatsD、记录写入数据库。
+// selector 返回输出的名称，没有对应的输出时写入 Default，Default 为 nil 时返回错误。
+type MultiSink struct {
+	selector func(item interface{}) string
+
+	// Default 没有匹配的输出时写入的输出。
+	Default Sink
+
+	mu    sync.RWMutex
+	sinks map[string]Sink
+	order []string
+}
+
+// NewMultiSink 新建按 selector 选择输出的输出。
+func NewMultiSink(selector func(item interface{}) string) *MultiSink {
+	return &MultiSink{selector: selector, sinks: make(map[string]Sink)}
+}
+
+// LabelSelector 返回按 map[string]interface{} 数据中 field 字段（按 . 分隔的路径）的值选择输出的 selector，
+// 值不是字符串时按 fmt.Sprint 转换，字段不存在时返回空字符串。
+func LabelSelector(field string) func(item interface{}) string {
+	return func(item interface{}) string {
+		v, ok := fieldValue(item, field)
+		if !ok || v == nil {
+			return ""
+		}
+		if s, ok := v.(string); ok {
+			return s
+		}
+		return fmt.Sprint(v)
+	}
+}
+
+// Route 把名为 name 的数据写入 sink，返回 ms 以便链式调用。
+func (ms *MultiSink) Route(name string, sink Sink) *MultiSink {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, ok := ms.sinks[name]; !ok {
+		ms.order = append(ms.order, name)
+	}
+	ms.sinks[name] = sink
+	return ms
+}
+
+// Write 实现 Sink 接口。
+func (ms *MultiSink) Write(out interface{}) error {
+	name := ms.selector(out)
+	ms.mu.RLock()
+	s, ok := ms.sinks[name]
+	ms.mu.RUnlock()
+	if !ok {
+		if ms.Default == nil {
+			return fmt.Errorf("multi sink: no sink for %q", name)
+		}
+		s = ms.Default
+	}
+	return s.Write(out)
+}
+
+// all 返回所有输出，Default 在最后。
+func (ms *MultiSink) all() []Sink {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	sinks := make([]Sink, 0, len(ms.order)+1)
+	for _, name := range ms.order {
+		sinks = append(sinks, ms.sinks[name])
+	}
+	if ms.Default != nil {
+		sinks = append(sinks, ms.Default)
+	}
+	return sinks
+}
+
+// Flush 实现 Flusher 接口，刷新所有输出。
+func (ms *MultiSink) Flush() error {
+	var flushers []Flusher
+	for _, s := range ms.all() {
+		if f, ok := s.(Flusher); ok {
+			flushers = append(flushers, f)
+		}
+	}
+	return flushAll(flushers)
+}
+
+// Close 实现 Sink 接口，关闭所有输出。
+func (ms *MultiSink) Close() error {
+	errBuf := bytes.Buffer{}
+	for _, s := range ms.all() {
+		if err := s.Close(); err != nil {
+			if errBuf.Len() > 0 {
+				errBuf.WriteString("; ")
+			}
+			errBuf.WriteString(err.Error())
+		}
+	}
+	if errBuf.Len() > 0 {
+		return errors.New(errBuf.String())
+	}
+	return nil
+}
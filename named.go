@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// AddNamedHandler 在处理链末尾添加名为 name 的处理器，之后可以按名称插入、替换或删除。
+// name 已存在时返回错误。
+func (h *Handlers) AddNamedHandler(name string, handler Handler) error {
+	return h.editChain(func(l *list.List, names map[string]*list.Element) error {
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("handlers: handler %q already exists", name)
+		}
+		names[name] = l.PushBack(handler)
+		return nil
+	})
+}
+
+// InsertBefore 在名为 name 的处理器之前插入处理器。
+func (h *Handlers) InsertBefore(name string, handler Handler) error {
+	return h.editNamed(name, func(l *list.List, names map[string]*list.Element, e *list.Element) {
+		l.InsertBefore(handler, e)
+	})
+}
+
+// InsertAfter 在名为 name 的处理器之后插入处理器。
+func (h *Handlers) InsertAfter(name string, handler Handler) error {
+	return h.editNamed(name, func(l *list.List, names map[string]*list.Element, e *list.Element) {
+		l.InsertAfter(handler, e)
+	})
+}
+
+// ReplaceHandler 把名为 name 的处理器替换为 handler，名称保持不变。
+func (h *Handlers) ReplaceHandler(name string, handler Handler) error {
+	return h.editNamed(name, func(l *list.List, names map[string]*list.Element, e *list.Element) {
+		names[name] = l.InsertAfter(handler, e)
+		l.Remove(e)
+	})
+}
+
+// RemoveHandler 从处理链中删除名为 name 的处理器。
+func (h *Handlers) RemoveHandler(name string) error {
+	return h.editNamed(name, func(l *list.List, names map[string]*list.Element, e *list.Element) {
+		l.Remove(e)
+		delete(names, name)
+	})
+}
+
+// NamedHandler 返回名为 name 的处理器。
+func (h *Handlers) NamedHandler(name string) (Handler, bool) {
+	h.chainMu.Lock()
+	defer h.chainMu.Unlock()
+	e, ok := h.names[name]
+	if !ok {
+		return nil, false
+	}
+	return e.Value.(Handler), true
+}
+
+// editNamed 对处理链的副本中名为 name 的处理器调用 fn，见 editChain。
+// 正在处理数据源时不用等待，修改从下一条数据开始生效（流水线模式下从下一个源开始）；中间件在下次 Run 时生效。
+func (h *Handlers) editNamed(name string, fn func(l *list.List, names map[string]*list.Element, e *list.Element)) error {
+	return h.editChain(func(l *list.List, names map[string]*list.Element) error {
+		e, ok := names[name]
+		if !ok {
+			return fmt.Errorf("handlers: handler %q not found", name)
+		}
+		fn(l, names, e)
+		return nil
+	})
+}
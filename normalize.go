@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// StringOp 字符串规范化步骤。
+type StringOp func(s string) string
+
+// NormalizeString 返回依次执行 ops 的 Converter，只处理 string 和 []byte 类型的值，
+// 配合 NewFieldConverter 可以为每个字段指定不同的规范化步骤。
+func NormalizeString(ops ...StringOp) Converter {
+	return func(v interface{}) (interface{}, error) {
+		switch s := v.(type) {
+		case string:
+			return applyStringOps(s, ops), nil
+		case []byte:
+			return []byte(applyStringOps(string(s), ops)), nil
+		}
+		return nil, fmt.Errorf("cannot normalize %T", v)
+	}
+}
+
+func applyStringOps(s string, ops []StringOp) string {
+	for _, op := range ops {
+		s = op(s)
+	}
+	return s
+}
+
+// NewStringNormalizer 新建处理器，对 string 和 []byte 数据本身依次执行 ops，其他类型原样通过。
+func NewStringNormalizer(ops ...StringOp) Handler {
+	conv := NormalizeString(ops...)
+	return HandlerFunc(func(in interface{}) (interface{}, error) {
+		switch in.(type) {
+		case string, []byte:
+			return conv(in)
+		}
+		return in, nil
+	})
+}
+
+// TrimSpace 去掉首尾的空白。
+func TrimSpace(s string) string { return strings.TrimSpace(s) }
+
+// ToLower 转换为小写。
+func ToLower(s string) string { return strings.ToLower(s) }
+
+// ToUpper 转换为大写。
+func ToUpper(s string) string { return strings.ToUpper(s) }
+
+// CaseFold 大小写折叠，用于不区分大小写的比较，例如 "Straße" 和 "STRASSE" 分别得到 "straße" 和 "strasse"。
+// 只做逐字符的简单折叠，不展开 ß 等多字符折叠。
+func CaseFold(s string) string {
+	return strings.Map(func(r rune) rune {
+		return unicode.ToLower(unicode.ToUpper(r))
+	}, s)
+}
+
+// CollapseSpace 把连续的空白（包括换行和制表符）合并为一个空格，并去掉首尾的空白。
+func CollapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// StripAccents 去掉拉丁字母上的重音符号，例如 "Crème Brûlée" 得到 "Creme Brulee"。
+// 只覆盖 Latin-1 和 Latin Extended-A 中的常见字母；完整的 Unicode 处理见 textnorm 子包。
+func StripAccents(s string) string {
+	return strings.Map(func(r rune) rune {
+		if b, ok := accentBase[r]; ok {
+			return b
+		}
+		return r
+	}, s)
+}
+
+// accentBase 带重音的字母到基本字母的映射。
+var accentBase = func() map[rune]rune {
+	m := make(map[rune]rune)
+	for base, accented := range map[rune]string{
+		'A': "ÀÁÂÃÄÅĀĂĄ", 'a': "àáâãäåāăą",
+		'C': "ÇĆĈĊČ", 'c': "çćĉċč",
+		'D': "ĎĐ", 'd': "ďđ",
+		'E': "ÈÉÊËĒĔĖĘĚ", 'e': "èéêëēĕėęě",
+		'G': "ĜĞĠĢ", 'g': "ĝğġģ",
+		'H': "ĤĦ", 'h': "ĥħ",
+		'I': "ÌÍÎÏĨĪĬĮİ", 'i': "ìíîïĩīĭįı",
+		'J': "Ĵ", 'j': "ĵ",
+		'K': "Ķ", 'k': "ķ",
+		'L': "ĹĻĽĿŁ", 'l': "ĺļľŀł",
+		'N': "ÑŃŅŇ", 'n': "ñńņň",
+		'O': "ÒÓÔÕÖØŌŎŐ", 'o': "òóôõöøōŏő",
+		'R': "ŔŖŘ", 'r': "ŕŗř",
+		'S': "ŚŜŞŠ", 's': "śŝşš",
+		'T': "ŢŤŦ", 't': "ţťŧ",
+		'U': "ÙÚÛÜŨŪŬŮŰŲ", 'u': "ùúûüũūŭůűų",
+		'W': "Ŵ", 'w': "ŵ",
+		'Y': "ÝŶŸ", 'y': "ýÿŷ",
+		'Z': "ŹŻŽ", 'z': "źżž",
+	} {
+		for _, r := range accented {
+			m[r] = base
+		}
+	}
+	return m
+}()
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RunSummary 一次 Run 的摘要，传给 OnRunComplete 和 OnRunFailed。
+type RunSummary struct {
+	Start       time.Time     `json:"start"`
+	Elapsed     time.Duration `json:"elapsed"`
+	SourcesDone int           `json:"sources_done"` // 已处理完的源
+	SourcesLeft int           `json:"sources_left"` // 尚未处理的源
+	Items       int64         `json:"items"`        // 所有源读取的数据条数
+	Bytes       int64         `json:"bytes"`        // 所有源读取的字节数
+	Err         string        `json:"error,omitempty"`
+
+	Contracts []ContractReport `json:"contracts,omitempty"` // 处理链中数据合约的检查结果
+	Schemas   []SchemaReport   `json:"schemas,omitempty"`   // 处理链中推断出的 Schema
+
+	Percentiles []PercentileSummary `json:"percentiles,omitempty"` // 处理链中分位数统计的结果
+}
+
+// notifyRun 根据 Run 的结果调用 OnRunComplete 或 OnRunFailed。
+func (h *Handlers) notifyRun(start time.Time, err error) {
+	h.RLock()
+	onComplete, onFailed := h.OnRunComplete, h.OnRunFailed
+	h.RUnlock()
+	if onComplete == nil && onFailed == nil {
+		return
+	}
+	sum := h.summary(start, err)
+	if err != nil {
+		if onFailed != nil {
+			onFailed(sum)
+		}
+		return
+	}
+	if onComplete != nil {
+		onComplete(sum)
+	}
+}
+
+// summary 生成开始于 start、结果为 err 的 Run 的摘要。
+func (h *Handlers) summary(start time.Time, err error) RunSummary {
+	sum := RunSummary{
+		Start:       start,
+		Elapsed:     time.Since(start),
+		SourcesDone: listLen(h.doneSrc),
+		SourcesLeft: listLen(h.todoSrc),
+	}
+	sum.Items, sum.Bytes = h.totals()
+	sum.Contracts = h.contractReports()
+	sum.Schemas = h.schemaReports()
+	sum.Percentiles = h.percentileReports()
+	if err != nil {
+		sum.Err = err.Error()
+	}
+	return sum
+}
+
+func listLen(l *safeList) int {
+	if l == nil {
+		return 0
+	}
+	l.RLock()
+	defer l.RUnlock()
+	return l.Len()
+}
+
+// WebhookNotifier 把 RunSummary POST 到指定地址，用于运行结束后通知运维人员。
+type WebhookNotifier struct {
+	URL string
+	// Client 发送请求的客户端，为 nil 时使用 http.DefaultClient。
+	Client *http.Client
+	// Format 把摘要转换成 JSON 请求体，为 nil 时直接编码 RunSummary。
+	Format func(sum RunSummary) interface{}
+	// OnError 通知失败时调用。
+	OnError func(err error)
+}
+
+// NewWebhookNotifier 新建 webhook 通知器，请求体为 RunSummary 的 JSON。
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url}
+}
+
+// NewSlackNotifier 新建 Slack incoming webhook 通知器。
+func NewSlackNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL: url,
+		Format: func(sum RunSummary) interface{} {
+			text := fmt.Sprintf("handlers run finished in %s: %d sources done, %d left",
+				sum.Elapsed, sum.SourcesDone, sum.SourcesLeft)
+			if sum.Err != "" {
+				text = fmt.Sprintf("handlers run failed after %s: %s (%d sources done, %d left)",
+					sum.Elapsed, sum.Err, sum.SourcesDone, sum.SourcesLeft)
+			}
+			return map[string]string{"text": text}
+		},
+	}
+}
+
+// Notify 发送通知。
+func (wn *WebhookNotifier) Notify(sum RunSummary) error {
+	var body interface{} = sum
+	if wn.Format != nil {
+		body = wn.Format(sum)
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	client := wn.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(wn.URL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook notify: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Hook 可以直接赋值给 OnRunComplete 或 OnRunFailed，通知失败时调用 OnError。
+func (wn *WebhookNotifier) Hook(sum RunSummary) {
+	if err := wn.Notify(sum); err != nil && wn.OnError != nil {
+		wn.OnError(err)
+	}
+}
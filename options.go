@@ -0,0 +1,97 @@
+package handlers
+
+import "time"
+
+// Option 配置 Handlers，在 NewHandlers 或 New 中使用，避免 Run 开始后再修改字段。
+type Option func(h *Handlers)
+
+// NewHandlers 按 opts 新建 Handlers。
+func NewHandlers(opts ...Option) *Handlers {
+	h := &Handlers{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// WithErrCheck 设置 ErrCheck。
+func WithErrCheck(fn func(err error) (goon bool)) Option {
+	return func(h *Handlers) { h.ErrCheck = fn }
+}
+
+// WithConcurrency 同 SetConcurrency。
+func WithConcurrency(n int) Option {
+	return func(h *Handlers) { h.SetConcurrency(n) }
+}
+
+// WithQuantum 同 SetQuantum。
+func WithQuantum(k int) Option {
+	return func(h *Handlers) { h.SetQuantum(k) }
+}
+
+// WithHeartbeat 同 SetHeartbeat。
+func WithHeartbeat(d time.Duration) Option {
+	return func(h *Handlers) { h.SetHeartbeat(d) }
+}
+
+// WithWatermarks 同 SetWatermarks。
+func WithWatermarks(high, low int) Option {
+	return func(h *Handlers) { h.SetWatermarks(high, low) }
+}
+
+// WithRejecter 同 SetRejecter。
+func WithRejecter(r Rejecter) Option {
+	return func(h *Handlers) { h.SetRejecter(r) }
+}
+
+// WithStrict 同 SetStrict。
+func WithStrict(strict bool) Option {
+	return func(h *Handlers) { h.SetStrict(strict) }
+}
+
+// WithDaemon 同 SetDaemon。
+func WithDaemon(on bool) Option {
+	return func(h *Handlers) { h.SetDaemon(on) }
+}
+
+// WithPipelining 同 EnablePipelining。
+func WithPipelining(bufSize int) Option {
+	return func(h *Handlers) { h.EnablePipelining(bufSize) }
+}
+
+// WithProfile 同 SetProfile。
+func WithProfile(profile string) Option {
+	return func(h *Handlers) { h.SetProfile(profile) }
+}
+
+// WithMiddleware 同 Use，可以用来接入日志、指标、跟踪等。
+func WithMiddleware(mw ...Middleware) Option {
+	return func(h *Handlers) { h.Use(mw...) }
+}
+
+// WithConfig 同 Apply。
+func WithConfig(c Config) Option {
+	return func(h *Handlers) { h.Apply(c) }
+}
+
+// WithCheckpoint 同 SetCheckpoint。
+func WithCheckpoint(store CheckpointStore, every time.Duration) Option {
+	return func(h *Handlers) { h.SetCheckpoint(store, every) }
+}
+
+// WithLogger 同 SetLogger。
+func WithLogger(l Logger) Option {
+	return func(h *Handlers) { h.SetLogger(l) }
+}
+
+// WithRunHooks 设置 OnRunComplete 和 OnRunFailed，为 nil 的不修改。
+func WithRunHooks(onComplete, onFailed func(sum RunSummary)) Option {
+	return func(h *Handlers) {
+		if onComplete != nil {
+			h.OnRunComplete = onComplete
+		}
+		if onFailed != nil {
+			h.OnRunFailed = onFailed
+		}
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/qn-zyc/handlers/oteltrace
+
+go 1.21
+
+require (
+	github.com/qn-zyc/handlers v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+)
+
+replace github.com/qn-zyc/handlers => ../
@@ -0,0 +1,145 @@
+// Package oteltrace 用 OpenTelemetry 跟踪 handlers 的运行：每次 Run 一个 span，
+// 每个源一个子 span，每次处理器调用（处理 Batcher 的输出时即每批）一个孙 span。
+//
+//	t := oteltrace.New(h, otel.Tracer("etl"))
+//	h.Use(t.Middleware())
+//
+// oteltrace 是单独的模块（见 oteltrace/go.mod），handlers 本身不依赖 OpenTelemetry。
+package oteltrace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/qn-zyc/handlers"
+)
+
+// Tracer 通过 Handlers.OnEvent 创建 Run 和源的 span，通过 Middleware 创建处理器的 span。
+// 处理器的 span 的父 span 为当前的源，同时处理多个源时（SetConcurrency 或 SetQuantum）
+// 无法确定数据来自哪个源，父 span 为 Run 的 span。
+type Tracer struct {
+	tracer trace.Tracer
+
+	mu      sync.Mutex
+	runCtx  context.Context
+	run     trace.Span
+	sources map[handlers.Source]sourceSpan
+}
+
+type sourceSpan struct {
+	ctx  context.Context
+	span trace.Span
+}
+
+// New 新建跟踪 h 的 Tracer。
+func New(h *handlers.Handlers, tracer trace.Tracer) *Tracer {
+	t := &Tracer{
+		tracer:  tracer,
+		runCtx:  context.Background(),
+		sources: make(map[handlers.Source]sourceSpan),
+	}
+	h.OnEvent(t.onEvent)
+	return t
+}
+
+func (t *Tracer) onEvent(ev handlers.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch ev.Kind {
+	case handlers.EventRunStart:
+		t.runCtx, t.run = t.tracer.Start(context.Background(), "handlers.run")
+	case handlers.EventRunStop:
+		// 因为出错而结束的源没有 EventSourceExhausted。
+		for src, ss := range t.sources {
+			endSpan(ss.span, ev.Err)
+			delete(t.sources, src)
+		}
+		if t.run != nil {
+			endSpan(t.run, ev.Err)
+		}
+		t.runCtx, t.run = context.Background(), nil
+	case handlers.EventSourceOpened:
+		ctx, span := t.tracer.Start(t.runCtx, "handlers.source",
+			trace.WithAttributes(attribute.String("handlers.source", sourceName(ev.Source))))
+		t.sources[ev.Source] = sourceSpan{ctx, span}
+	case handlers.EventSourceExhausted:
+		ss, ok := t.sources[ev.Source]
+		if !ok {
+			return
+		}
+		delete(t.sources, ev.Source)
+		ss.span.SetAttributes(attribute.Int64("handlers.items", ev.Items))
+		err := ev.Err
+		if err == io.EOF {
+			err = nil
+		}
+		endSpan(ss.span, err)
+	}
+}
+
+// Context 返回当前源的 span 的 context，同时处理多个源时为 Run 的 span 的 context。
+// 调用外部服务的处理器可以用它传递跟踪信息。
+func (t *Tracer) Context() context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.sources) == 1 {
+		for _, ss := range t.sources {
+			return ss.ctx
+		}
+	}
+	return t.runCtx
+}
+
+// Middleware 返回为每次处理器调用创建 span 的中间件，需要通过 Handlers.Use 添加。
+func (t *Tracer) Middleware() handlers.Middleware {
+	return func(next handlers.Handler) handlers.Handler {
+		name := handlerName(next)
+		return handlers.HandlerFunc(func(in interface{}) (interface{}, error) {
+			_, span := t.tracer.Start(t.Context(), "handlers.handle",
+				trace.WithAttributes(attribute.String("handlers.handler", name)))
+			if batch, ok := in.([]interface{}); ok {
+				span.SetAttributes(attribute.Int("handlers.batch_size", len(batch)))
+			}
+			out, err := next.Handle(in)
+			spanErr := err
+			if errors.Is(err, handlers.ErrSkip) || (err == nil && out == handlers.None) {
+				span.SetAttributes(attribute.Bool("handlers.dropped", true))
+				spanErr = nil
+			}
+			endSpan(span, spanErr)
+			return out, err
+		})
+	}
+}
+
+// endSpan 记录错误并结束 span。
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// sourceName 返回源的属性值。
+func sourceName(src handlers.Source) string {
+	if ds, ok := src.(handlers.DescribedSource); ok {
+		return ds.Name()
+	}
+	return fmt.Sprintf("%T", src)
+}
+
+// handlerName 返回处理器的属性值。
+func handlerName(h handlers.Handler) string {
+	if s, ok := h.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", h)
+}
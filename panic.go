@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError 处理器 panic 时转换成的错误，和其他错误一样交给出错策略、Rejecter 和 ErrCheck 处理。
+type PanicError struct {
+	Handler string      // 处理器名称
+	Value   interface{} // recover 得到的值
+	Stack   []byte      // panic 时的调用栈
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler %s panic: %v", e.Handler, e.Value)
+}
+
+// callHandler 调用 hd.Handle，把 panic 转换为 *PanicError。
+func callHandler(hd Handler, in interface{}) (out interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = nil, &PanicError{Handler: handlerName(hd), Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return hd.Handle(in)
+}
+
+// callMarker 调用处理器 hd 的 HandleMarker，把 panic 转换为 *PanicError。
+func callMarker(hd Handler, mh MarkerHandler, m Marker) (out interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = nil, &PanicError{Handler: handlerName(hd), Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return mh.HandleMarker(m)
+}
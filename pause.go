@@ -0,0 +1,67 @@
+package handlers
+
+import "context"
+
+// isActive Run 是否正在执行。
+func isActive(state int32) bool {
+	return state == StatusRunning || state == StatusDraining || state == StatusPaused
+}
+
+// Pause 暂停从数据源拉取数据，正在处理的数据继续处理，待处理和已处理队列保持不变，
+// 例如在下游维护期间使用。只在 StatusRunning 时生效，状态变为 StatusPaused。
+// 暂停期间 Drain 和 Stop 仍然有效，RunContext 的 ctx 结束时也会停止等待。
+func (h *Handlers) Pause() {
+	h.Lock()
+	if h.state != StatusRunning || h.pauseCh != nil {
+		h.Unlock()
+		return
+	}
+	h.pauseCh = make(chan struct{})
+	h.state = StatusPaused
+	onChange := h.OnStateChange
+	h.Unlock()
+	if onChange != nil {
+		onChange(StatusRunning, StatusPaused)
+	}
+}
+
+// Resume 恢复 Pause 暂停的 Run，状态变回 StatusRunning。
+func (h *Handlers) Resume() {
+	h.Lock()
+	if h.pauseCh == nil {
+		h.Unlock()
+		return
+	}
+	h.resumeLocked()
+	paused := h.state == StatusPaused
+	if paused {
+		h.state = StatusRunning
+	}
+	onChange := h.OnStateChange
+	h.Unlock()
+	if onChange != nil && paused {
+		onChange(StatusPaused, StatusRunning)
+	}
+}
+
+// resumeLocked 唤醒等待中的 Run，调用方需持有 h 的锁。
+func (h *Handlers) resumeLocked() {
+	if h.pauseCh != nil {
+		close(h.pauseCh)
+		h.pauseCh = nil
+	}
+}
+
+// waitPaused 暂停时等待 Resume、Drain、Stop 或 ctx 结束。
+func (h *Handlers) waitPaused(ctx context.Context) {
+	h.RLock()
+	ch := h.pauseCh
+	h.RUnlock()
+	if ch == nil {
+		return
+	}
+	select {
+	case <-ch:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileReport RunPerFile 中一个文件的处理结果。
+type FileReport struct {
+	File string `json:"file"`
+	RunSummary
+}
+
+// OK 文件是否处理成功。
+func (fr FileReport) OK() bool {
+	return fr.Err == ""
+}
+
+// RunPerFile 把匹配 pattern（同 filepath.Glob）的每个文件作为独立的任务处理：build 为每个文件的源
+// 新建 Handlers（包括处理链和输出，可以使用 New(...).From(src)...Build()），每个文件单独 Run，
+// 处理链的状态和输出互不影响，一个文件失败不影响其他文件。Run 返回错误的文件视为失败，
+// 处理出错时需要视为失败的可以使用 WithErrCheck(StopOnError)。
+// 最多同时处理 parallel 个文件，<= 1 时依次处理。返回每个文件的结果，顺序与文件名的顺序相同，
+// 只有 pattern 有误时才返回错误。
+func RunPerFile(pattern string, parallel int, build func(src *FileSource) (*Handlers, error)) ([]FileReport, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if parallel <= 1 {
+		parallel = 1
+	}
+	reports := make([]FileReport, len(files))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, file := range files {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, file string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			reports[i] = runFile(file, build)
+		}(i, file)
+	}
+	wg.Wait()
+	return reports, nil
+}
+
+// runFile 处理一个文件。
+func runFile(file string, build func(src *FileSource) (*Handlers, error)) FileReport {
+	start := time.Now()
+	fail := func(err error) FileReport {
+		return FileReport{File: file, RunSummary: RunSummary{Start: start, Elapsed: time.Since(start), Err: err.Error()}}
+	}
+	src, err := NewFileSrc(file)
+	if err != nil {
+		return fail(err)
+	}
+	defer src.Close()
+	h, err := build(src)
+	if err != nil {
+		return fail(err)
+	}
+	err = h.Run()
+	return FileReport{File: file, RunSummary: h.summary(start, err)}
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Histogram 对数分桶的流式直方图，分位数的相对误差不超过创建时指定的精度，内存只和数值的范围有关。
+// 小于等于 0 的值计入单独的桶，分位数为 0。
+type Histogram struct {
+	gamma    float64
+	logGamma float64
+	buckets  map[int]uint64
+	zeros    uint64
+	count    uint64
+	sum      float64
+	min, max float64
+}
+
+// NewHistogram 新建直方图，relAcc 为分位数的相对精度，例如 0.01 表示误差在 1% 以内。
+func NewHistogram(relAcc float64) *Histogram {
+	if relAcc <= 0 || relAcc >= 1 {
+		relAcc = 0.01
+	}
+	gamma := (1 + relAcc) / (1 - relAcc)
+	return &Histogram{gamma: gamma, logGamma: math.Log(gamma), buckets: make(map[int]uint64)}
+}
+
+// Record 记录一个值。
+func (hg *Histogram) Record(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	if hg.count == 0 || v < hg.min {
+		hg.min = v
+	}
+	if hg.count == 0 || v > hg.max {
+		hg.max = v
+	}
+	hg.count++
+	hg.sum += v
+	if v <= 0 {
+		hg.zeros++
+		return
+	}
+	hg.buckets[int(math.Ceil(math.Log(v)/hg.logGamma))]++
+}
+
+// Count 返回记录的值的个数。
+func (hg *Histogram) Count() uint64 { return hg.count }
+
+// Quantile 返回分位数 q（0 到 1）的近似值，没有数据时返回 0。
+func (hg *Histogram) Quantile(q float64) float64 {
+	if hg.count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return hg.min
+	}
+	if q >= 1 {
+		return hg.max
+	}
+	rank := uint64(q * float64(hg.count-1))
+	if rank < hg.zeros {
+		return 0
+	}
+	seen := hg.zeros
+	keys := make([]int, 0, len(hg.buckets))
+	for k := range hg.buckets {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	for _, k := range keys {
+		seen += hg.buckets[k]
+		if seen > rank {
+			v := 2 * math.Pow(hg.gamma, float64(k)) / (hg.gamma + 1)
+			return math.Max(hg.min, math.Min(hg.max, v))
+		}
+	}
+	return hg.max
+}
+
+// Merge 把 o 合并到 hg 中，两者的精度需相同。
+func (hg *Histogram) Merge(o *Histogram) {
+	if o.count == 0 {
+		return
+	}
+	if hg.count == 0 || o.min < hg.min {
+		hg.min = o.min
+	}
+	if hg.count == 0 || o.max > hg.max {
+		hg.max = o.max
+	}
+	for k, n := range o.buckets {
+		hg.buckets[k] += n
+	}
+	hg.zeros += o.zeros
+	hg.count += o.count
+	hg.sum += o.sum
+}
+
+// PercentileSummary 一组数值的统计结果。
+type PercentileSummary struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"` // 统计开始的时间
+	End   time.Time `json:"end"`   // 统计结束的时间
+	Count uint64    `json:"count"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Mean  float64   `json:"mean"`
+	P50   float64   `json:"p50"`
+	P90   float64   `json:"p90"`
+	P95   float64   `json:"p95"`
+	P99   float64   `json:"p99"`
+}
+
+func (hg *Histogram) summary(name string, start, end time.Time) PercentileSummary {
+	s := PercentileSummary{Name: name, Start: start, End: end, Count: hg.count}
+	if hg.count > 0 {
+		s.Min, s.Max, s.Mean = hg.min, hg.max, hg.sum/float64(hg.count)
+		s.P50, s.P90, s.P95, s.P99 = hg.Quantile(0.5), hg.Quantile(0.9), hg.Quantile(0.95), hg.Quantile(0.99)
+	}
+	return s
+}
+
+// Percentiles 统计数值字段的分位数，数据原样交给下一个处理器。
+// window > 0 时每个窗口结束后输出一条 PercentileSummary：设置了 SideOutput 时发送到旁路输出，
+// 否则和触发它的数据一起以 Emit 输出（先输出统计结果）。收到 MarkerEndOfWindow 或 MarkerEndOfSource 时
+// 也会输出当前窗口的统计结果。整个运行的统计结果汇总到 RunSummary.Percentiles。
+type Percentiles struct {
+	name   string
+	field  string
+	window time.Duration
+	emit   SideEmitter
+
+	// SideOutput 不为空时窗口统计结果发送到该旁路输出。
+	SideOutput string
+
+	mu       sync.Mutex
+	cur      *Histogram
+	curStart time.Time
+	total    *Histogram
+	start    time.Time
+	relAcc   float64
+}
+
+// NewPercentiles 新建分位数统计处理器，field 为按 . 分隔的数值字段路径，为空时数据本身应为数值。
+// time.Duration 类型的值按毫秒统计。
+func NewPercentiles(name, field string, window time.Duration) *Percentiles {
+	return &Percentiles{name: name, field: field, window: window, relAcc: 0.01,
+		cur: NewHistogram(0.01), total: NewHistogram(0.01)}
+}
+
+// String 返回处理器名称。
+func (p *Percentiles) String() string { return "Percentiles(" + p.name + ")" }
+
+// SetSideEmitter 实现 SideOutputHandler 接口。
+func (p *Percentiles) SetSideEmitter(emit SideEmitter) { p.emit = emit }
+
+// Handle 实现 Handler 接口。
+func (p *Percentiles) Handle(in interface{}) (interface{}, error) {
+	v := in
+	if p.field != "" {
+		v, _ = fieldValue(in, p.field)
+	}
+	if v == nil {
+		return in, nil
+	}
+	var f float64
+	if d, ok := v.(time.Duration); ok {
+		f = float64(d) / float64(time.Millisecond)
+	} else if n, ok := normalizeValue(v).(float64); ok {
+		f = n
+	} else {
+		return nil, fmt.Errorf("percentiles %s: %T is not a number", p.name, v)
+	}
+
+	now := time.Now()
+	p.mu.Lock()
+	var sum *PercentileSummary
+	if p.window > 0 && !p.curStart.IsZero() && now.Sub(p.curStart) >= p.window {
+		sum = p.rotate(now)
+	}
+	if p.start.IsZero() {
+		p.start = now
+	}
+	if p.curStart.IsZero() {
+		p.curStart = now
+	}
+	p.cur.Record(f)
+	p.total.Record(f)
+	p.mu.Unlock()
+	if sum == nil {
+		return in, nil
+	}
+	return p.output(*sum, in)
+}
+
+// rotate 结束当前窗口，返回其统计结果。调用方需持有 p.mu。
+func (p *Percentiles) rotate(now time.Time) *PercentileSummary {
+	if p.cur.Count() == 0 {
+		return nil
+	}
+	s := p.cur.summary(p.name, p.curStart, now)
+	p.cur = NewHistogram(p.relAcc)
+	p.curStart = time.Time{}
+	return &s
+}
+
+// output 输出窗口统计结果，in 不为 nil 时和它一起输出。
+func (p *Percentiles) output(sum PercentileSummary, in interface{}) (interface{}, error) {
+	if p.SideOutput == "" {
+		if in == nil {
+			return sum, nil
+		}
+		return Emit{sum, in}, nil
+	}
+	if p.emit == nil {
+		return nil, fmt.Errorf("percentiles %s: side output %q not bound", p.name, p.SideOutput)
+	}
+	if err := p.emit(p.SideOutput, sum); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// HandleMarker 实现 MarkerHandler 接口。
+func (p *Percentiles) HandleMarker(m Marker) (interface{}, error) {
+	if m.Kind != MarkerEndOfWindow && m.Kind != MarkerEndOfSource && p.window <= 0 {
+		return nil, nil
+	}
+	p.mu.Lock()
+	var sum *PercentileSummary
+	if m.Kind == MarkerEndOfWindow || m.Kind == MarkerEndOfSource ||
+		(!p.curStart.IsZero() && m.Time.Sub(p.curStart) >= p.window) {
+		sum = p.rotate(m.Time)
+	}
+	p.mu.Unlock()
+	if sum == nil {
+		return nil, nil
+	}
+	return p.output(*sum, nil)
+}
+
+// Report 返回整个运行的统计结果。
+func (p *Percentiles) Report() PercentileSummary {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.total.summary(p.name, p.start, time.Now())
+}
+
+// percentileReports 返回处理链中所有 Percentiles 的统计结果。
+func (h *Handlers) percentileReports() []PercentileSummary {
+	var reports []PercentileSummary
+	h.eachHandler(func(v Handler) {
+		if p, ok := v.(*Percentiles); ok {
+			reports = append(reports, p.Report())
+		}
+	})
+	return reports
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// EnablePipelining 启用流水线模式，在下次 Run 时生效：处理链中的每个处理器在各自的 goroutine 中运行，
+// 相邻的处理器之间通过容量为 bufSize 的 channel 连接，慢的处理器和快的处理器可以同时工作。
+// 每个处理器仍然依次处理数据，数据的顺序不变。
+// 和异步处理器一样，流水线中产生的错误不经过 Rejecter，在下一条数据读取前中止数据源。
+// 处理链中的异步处理器是流水线的最后一级，其后的处理器仍在回调中执行。
+func (h *Handlers) EnablePipelining(bufSize int) {
+	if bufSize < 0 {
+		bufSize = 0
+	}
+	h.Lock()
+	h.pipelined, h.pipeBuf = true, bufSize
+	h.Unlock()
+}
+
+// DisablePipelining 关闭流水线模式（默认）。
+func (h *Handlers) DisablePipelining() {
+	h.Lock()
+	h.pipelined = false
+	h.Unlock()
+}
+
+// pipeline 处理一个数据源时使用的流水线。
+type pipeline struct {
+	h      *Handlers
+	elems  []*list.Element
+	chans  []chan interface{} // chans[i] 为第 i 级的输入
+	wg     sync.WaitGroup     // 各级的 goroutine
+	failed int32              // 为 1 时丢弃之后的数据
+	fl     *flight            // 数据源的 flight
+}
+
+// startPipeline 为当前的处理链启动流水线，处理链为空时返回 nil。
+// 流水线在整个数据源中使用启动时的处理链，处理链的修改从下一个源开始生效。
+func (h *Handlers) startPipeline(bufSize int, fl *flight) *pipeline {
+	p := &pipeline{h: h, fl: fl}
+	for e := h.chainFront(); e != nil; e = e.Next() {
+		p.elems = append(p.elems, e)
+		if _, ok := e.Value.(*asyncStage); ok {
+			break
+		}
+	}
+	if len(p.elems) == 0 {
+		return nil
+	}
+	p.chans = make([]chan interface{}, len(p.elems))
+	for i := range p.chans {
+		p.chans[i] = make(chan interface{}, bufSize)
+	}
+	for i := range p.elems {
+		p.wg.Add(1)
+		go p.run(i)
+	}
+	return p
+}
+
+// submit 把从数据源读到的数据交给流水线。
+func (p *pipeline) submit(d interface{}) {
+	p.enter()
+	p.chans[0] <- d
+}
+
+// stop 等待流水线中的数据处理完毕并结束所有 goroutine。
+func (p *pipeline) stop() {
+	close(p.chans[0])
+	p.wg.Wait()
+}
+
+// enter 和 leave 记录流水线中的每条数据，使源的 flight 和 InFlight 包含它们。
+func (p *pipeline) enter() {
+	p.h.flightMu.Lock()
+	p.h.inFlight++
+	p.h.flightMu.Unlock()
+	p.fl.add()
+}
+
+func (p *pipeline) leave() {
+	p.h.release()
+	p.fl.done()
+}
+
+func (p *pipeline) fail(err error) {
+	atomic.StoreInt32(&p.failed, 1)
+	p.fl.fail(err)
+}
+
+// run 第 i 级的 goroutine。
+func (p *pipeline) run(i int) {
+	defer p.wg.Done()
+	if i+1 < len(p.chans) {
+		defer close(p.chans[i+1])
+	}
+	h := p.h
+	e := p.elems[i]
+	strict := h.isStrict()
+	for d := range p.chans[i] {
+		if atomic.LoadInt32(&p.failed) == 1 || h.ctxErr() != nil {
+			p.leave()
+			continue
+		}
+		if as, ok := e.Value.(*asyncStage); ok {
+			h.dispatchAsync(p.fl, e, as, d)
+			p.leave()
+			continue
+		}
+		if th, ok := e.Value.(TypedHandler); ok && strict {
+			if err := checkInType(th, d); err != nil {
+				p.fail(err)
+				p.leave()
+				continue
+			}
+		}
+		out, err := callHandler(h.handlerAt(e), d)
+		h.observe(e, d, out, err)
+		if errors.Is(err, ErrSkip) {
+			p.leave()
+			continue
+		}
+		if err != nil {
+			p.fail(err)
+			p.leave()
+			continue
+		}
+		p.send(i+1, out)
+	}
+}
+
+// send 把输出交给第 next 级，展开 Emit 并丢弃 None，最后一级的输出写入输出。
+func (p *pipeline) send(next int, out interface{}) {
+	switch v := out.(type) {
+	case Emit:
+		for _, item := range v {
+			p.enter()
+			p.send(next, item)
+		}
+		p.leave()
+		return
+	case dropped:
+		p.leave()
+		return
+	}
+	if next < len(p.chans) {
+		p.chans[next] <- out
+		return
+	}
+	if err := p.h.writeSinks(out); err != nil {
+		p.fail(err)
+	}
+	p.leave()
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Pivot 把长表（每条数据一个键值对）转换为宽表：GroupBy 字段相同的数据合并为一行，
+// KeyField 的值作为列名，ValueField 的值作为列值。数据为 map[string]interface{}。
+// 默认在源结束或窗口结束时按分组第一次出现的顺序输出所有行；Sorted 为 true 时认为数据已按分组排序，
+// 分组变化时立即输出上一行，不再缓存所有分组。
+type Pivot struct {
+	GroupBy    []string
+	KeyField   string
+	ValueField string
+	Sorted     bool
+
+	mu     sync.Mutex
+	rows   map[string]map[string]interface{}
+	order  []string
+	curKey string
+}
+
+// NewPivot 新建长表转宽表的处理器。
+func NewPivot(groupBy []string, keyField, valueField string) *Pivot {
+	return &Pivot{GroupBy: groupBy, KeyField: keyField, ValueField: valueField,
+		rows: make(map[string]map[string]interface{})}
+}
+
+// Handle 实现 Handler 接口，Sorted 为 false 时总是返回 None。
+func (p *Pivot) Handle(in interface{}) (interface{}, error) {
+	m, ok := in.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("pivot: expects map[string]interface{}, got %T", in)
+	}
+	col, ok := m[p.KeyField]
+	if !ok {
+		return nil, fmt.Errorf("pivot: missing key field %q", p.KeyField)
+	}
+	gk := groupKey(m, p.GroupBy)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out interface{} = None
+	if p.Sorted && len(p.order) > 0 && gk != p.curKey {
+		out = p.take()[0] // 已排序时只缓存了一个分组
+	}
+	row, ok := p.rows[gk]
+	if !ok {
+		row = make(map[string]interface{}, len(p.GroupBy)+1)
+		for _, f := range p.GroupBy {
+			row[f] = m[f]
+		}
+		p.rows[gk] = row
+		p.order = append(p.order, gk)
+	}
+	row[fmt.Sprint(col)] = m[p.ValueField]
+	p.curKey = gk
+	return out, nil
+}
+
+// HandleMarker 实现 MarkerHandler 接口，源结束或窗口结束时输出所有缓存的行。
+func (p *Pivot) HandleMarker(m Marker) (interface{}, error) {
+	if m.Kind != MarkerEndOfSource && m.Kind != MarkerEndOfWindow {
+		return nil, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.order) == 0 {
+		return nil, nil
+	}
+	return p.take(), nil
+}
+
+// take 按顺序取出所有缓存的行。调用方需持有 p.mu。
+func (p *Pivot) take() Emit {
+	out := make(Emit, 0, len(p.order))
+	for _, k := range p.order {
+		out = append(out, p.rows[k])
+	}
+	p.rows = make(map[string]map[string]interface{})
+	p.order = nil
+	return out
+}
+
+// groupKey 用 fields 的值拼出分组的键。
+func groupKey(m map[string]interface{}, fields []string) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = fmt.Sprint(m[f])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// Unpivot 把宽表转换为长表：每个列输出一条数据，包含 IDFields、KeyField（列名）和 ValueField（列值）。
+// Columns 为空时使用 IDFields 以外的所有字段，按列名排序；数据中没有的列被跳过。
+type Unpivot struct {
+	IDFields   []string
+	KeyField   string
+	ValueField string
+	Columns    []string
+}
+
+// NewUnpivot 新建宽表转长表的处理器，columns 为要展开的列。
+func NewUnpivot(idFields []string, keyField, valueField string, columns ...string) *Unpivot {
+	return &Unpivot{IDFields: idFields, KeyField: keyField, ValueField: valueField, Columns: columns}
+}
+
+// Handle 实现 Handler 接口，返回 Emit。
+func (u *Unpivot) Handle(in interface{}) (interface{}, error) {
+	m, ok := in.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unpivot: expects map[string]interface{}, got %T", in)
+	}
+	cols := u.Columns
+	if len(cols) == 0 {
+		id := make(map[string]bool, len(u.IDFields))
+		for _, f := range u.IDFields {
+			id[f] = true
+		}
+		for k := range m {
+			if !id[k] {
+				cols = append(cols, k)
+			}
+		}
+		sort.Strings(cols)
+	}
+	out := make(Emit, 0, len(cols))
+	for _, c := range cols {
+		v, ok := m[c]
+		if !ok {
+			continue
+		}
+		row := make(map[string]interface{}, len(u.IDFields)+2)
+		for _, f := range u.IDFields {
+			row[f] = m[f]
+		}
+		row[u.KeyField] = c
+		row[u.ValueField] = v
+		out = append(out, row)
+	}
+	return out, nil
+}
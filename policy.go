@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// PolicyRule 访问控制规则。When 为空或结果为 true 时规则生效：
+// Deny 为 true 时丢弃数据，否则删除 Strip 中列出的字段（按 . 分隔的路径）。
+type PolicyRule struct {
+	When  string
+	Deny  bool
+	Strip []string
+}
+
+type policyRule struct {
+	when  *Expr
+	deny  bool
+	strip [][]string
+}
+
+// Policy 按规则逐条检查数据，丢弃无权访问的数据或删除无权访问的字段，
+// 用于在多租户共享的输出之前执行数据访问规则。规则按顺序执行，数据被丢弃后不再检查后面的规则。
+// 删除字段只支持 map[string]interface{}，不修改原数据。
+type Policy struct {
+	rules []policyRule
+}
+
+// NewPolicy 新建访问控制处理器。
+func NewPolicy(rules ...PolicyRule) (*Policy, error) {
+	p := &Policy{}
+	for _, r := range rules {
+		pr := policyRule{deny: r.Deny}
+		if r.When != "" {
+			e, err := CompileExpr(r.When)
+			if err != nil {
+				return nil, err
+			}
+			pr.when = e
+		}
+		for _, f := range r.Strip {
+			pr.strip = append(pr.strip, strings.Split(f, "."))
+		}
+		p.rules = append(p.rules, pr)
+	}
+	return p, nil
+}
+
+// ParsePolicy 从策略文档中解析规则，每行一条，# 开头的行为注释：
+//
+//	deny if tenant != "acme"
+//	strip user.email, user.phone if role != "admin"
+//	strip password
+func ParsePolicy(doc string) (*Policy, error) {
+	var rules []PolicyRule
+	sc := bufio.NewScanner(strings.NewReader(doc))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r PolicyRule
+		body := line
+		if i := strings.Index(line, " if "); i >= 0 {
+			body, r.When = strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+4:])
+		}
+		switch {
+		case body == "deny":
+			r.Deny = true
+		case strings.HasPrefix(body, "strip "):
+			for _, f := range strings.Split(body[len("strip "):], ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					r.Strip = append(r.Strip, f)
+				}
+			}
+			if len(r.Strip) == 0 {
+				return nil, fmt.Errorf("policy line %d: strip without fields", n)
+			}
+		default:
+			return nil, fmt.Errorf("policy line %d: unknown rule %q", n, line)
+		}
+		rules = append(rules, r)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return NewPolicy(rules...)
+}
+
+// Handle 实现 Handler 接口。
+func (p *Policy) Handle(in interface{}) (interface{}, error) {
+	for _, r := range p.rules {
+		if r.when != nil {
+			ok, err := r.when.Bool(in)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		if r.deny {
+			return None, nil
+		}
+		for _, path := range r.strip {
+			m, ok := in.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("policy: cannot strip fields from %T", in)
+			}
+			in = stripField(m, path)
+		}
+	}
+	return in, nil
+}
+
+// stripField 返回删除了 path 字段的 m 的副本，path 上经过的 map 也会复制。
+// 字段不存在时返回 m 本身。
+func stripField(m map[string]interface{}, path []string) map[string]interface{} {
+	v, ok := m[path[0]]
+	if !ok {
+		return m
+	}
+	var child interface{}
+	if len(path) > 1 {
+		sub, ok := v.(map[string]interface{})
+		if !ok {
+			return m
+		}
+		stripped := stripField(sub, path[1:])
+		if len(stripped) == len(sub) {
+			return m
+		}
+		child = stripped
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	if len(path) > 1 {
+		out[path[0]] = child
+	} else {
+		delete(out, path[0])
+	}
+	return out
+}
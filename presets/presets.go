@@ -0,0 +1,246 @@
+// Package presets 常见处理流程的快速组装：数据源、解析、校验和输出都使用合理的默认值，
+// 返回的 *handlers.Builder 可以继续添加处理器、输出和选项，例如
+//
+//	b, err := presets.LogToJSON("/var/log/app/*.log", "app.json")
+//	h, err := b.Then(myFilter).Build()
+//	err = h.Run()
+//	h.CloseSinks()
+package presets
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/qn-zyc/handlers"
+)
+
+// fileSources 为匹配 glob 的每个文件新建一个源。
+func fileSources(glob string) ([]handlers.Source, error) {
+	files, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("presets: no file matches %q", glob)
+	}
+	srcs := make([]handlers.Source, 0, len(files))
+	for _, file := range files {
+		src, err := handlers.NewFileSrc(file)
+		if err != nil {
+			for _, s := range srcs {
+				s.(*handlers.FileSource).Close()
+			}
+			return nil, err
+		}
+		srcs = append(srcs, src)
+	}
+	return srcs, nil
+}
+
+// LogToJSON 把匹配 glob 的日志文件转换为 JSON 行写入 out，out 为空或 "-" 时写入标准输出。
+// 每行日志由 ParseLog 解析。
+func LogToJSON(glob, out string) (*handlers.Builder, error) {
+	srcs, err := fileSources(glob)
+	if err != nil {
+		return nil, err
+	}
+	var sink handlers.Sink
+	if out == "" || out == "-" {
+		sink = handlers.NewStdoutSink()
+	} else if sink, err = handlers.NewFileSink(out); err != nil {
+		return nil, err
+	}
+	return handlers.New().From(srcs...).Then(ParseLog()).To(sink), nil
+}
+
+// logfmtRe 匹配 logfmt 的一个键值对。
+var logfmtRe = regexp.MustCompile(`([\w.\-]+)=("(?:[^"\\]|\\.)*"|\S*)`)
+
+// ParseLog 返回解析日志行的处理器：JSON 对象原样解析；包含 key=value 的行按 logfmt 解析，
+// 其余部分放在 "message" 中；其他行整行作为 "message"。空行被丢弃。
+func ParseLog() handlers.Handler {
+	return handlers.HandlerFunc(func(in interface{}) (interface{}, error) {
+		line, ok := in.(string)
+		if !ok {
+			return in, nil
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) == "" {
+			return handlers.None, nil
+		}
+		if strings.HasPrefix(line, "{") {
+			m := make(map[string]interface{})
+			if err := json.Unmarshal([]byte(line), &m); err == nil {
+				return m, nil
+			}
+		}
+		m := make(map[string]interface{})
+		idx := logfmtRe.FindAllStringSubmatchIndex(line, -1)
+		rest := line
+		for i := len(idx) - 1; i >= 0; i-- {
+			loc := idx[i]
+			k, v := line[loc[2]:loc[3]], line[loc[4]:loc[5]]
+			if uv, err := unquote(v); err == nil {
+				v = uv
+			}
+			m[k] = v
+			rest = rest[:loc[0]] + rest[loc[1]:]
+		}
+		if rest = strings.Join(strings.Fields(rest), " "); rest != "" {
+			m["message"] = rest
+		}
+		return m, nil
+	})
+}
+
+func unquote(v string) (string, error) {
+	if len(v) < 2 || v[0] != '"' {
+		return v, nil
+	}
+	var s string
+	err := json.Unmarshal([]byte(v), &s)
+	return s, err
+}
+
+// CSV 解析 CSV 行的处理器：每个源的第一行为表头，之后的每行转换为 map[string]interface{}，
+// 列数与表头不同的行返回错误（可以通过 SetRejecter 记录而不中止数据源）。
+// 通过源结束的控制标记切换到下一个文件的表头，所以要求源按顺序处理（默认）。
+// 引号中的换行不受支持，因为文件源按行读取。
+type CSV struct {
+	Comma rune // 分隔符，为 0 时为 ','
+
+	mu     sync.Mutex
+	header []string
+}
+
+// Handle 实现 handlers.Handler 接口。
+func (c *CSV) Handle(in interface{}) (interface{}, error) {
+	line, ok := in.(string)
+	if !ok {
+		return in, nil
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return handlers.None, nil
+	}
+	r := csv.NewReader(strings.NewReader(line))
+	if c.Comma != 0 {
+		r.Comma = c.Comma
+	}
+	r.FieldsPerRecord = -1
+	rec, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.header == nil {
+		for i := range rec {
+			rec[i] = strings.TrimSpace(rec[i])
+		}
+		c.header = rec
+		return handlers.None, nil
+	}
+	if len(rec) != len(c.header) {
+		return nil, fmt.Errorf("presets: csv row has %d columns, header has %d", len(rec), len(c.header))
+	}
+	row := make(map[string]interface{}, len(rec))
+	for i, v := range rec {
+		row[c.header[i]] = v
+	}
+	return row, nil
+}
+
+// HandleMarker 实现 handlers.MarkerHandler 接口，源结束时清除表头。
+func (c *CSV) HandleMarker(m handlers.Marker) (interface{}, error) {
+	if m.Kind == handlers.MarkerEndOfSource {
+		c.mu.Lock()
+		c.header = nil
+		c.mu.Unlock()
+	}
+	return nil, nil
+}
+
+// CSVToDB 把匹配 glob 的 CSV 文件（第一行为表头）写入数据库表 table，driver 和 dsn 同 sql.Open，
+// 需要调用方导入对应的驱动。每行数据的列名来自表头。
+func CSVToDB(glob, driver, dsn, table string) (*handlers.Builder, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := NewSQLSink(db, table)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	srcs, err := fileSources(glob)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return handlers.New().From(srcs...).Then(&CSV{}).To(sink), nil
+}
+
+// identRe 允许的表名和列名，防止表头中的内容拼接到 SQL 中。
+var identRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// SQLSink 把 map[string]interface{} 逐条插入数据库表，Close 时关闭 db。
+type SQLSink struct {
+	db    *sql.DB
+	table string
+	// Placeholder 返回第 i 个（从 1 开始）参数的占位符，为 nil 时为 "?"，PostgreSQL 需要设置为 "$i"。
+	Placeholder func(i int) string
+}
+
+// NewSQLSink 新建写入 table 的输出。
+func NewSQLSink(db *sql.DB, table string) (*SQLSink, error) {
+	if !identRe.MatchString(table) {
+		return nil, fmt.Errorf("presets: invalid table name %q", table)
+	}
+	return &SQLSink{db: db, table: table}, nil
+}
+
+// Write 实现 handlers.Sink 接口。
+func (ss *SQLSink) Write(out interface{}) error {
+	row, ok := out.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("presets: sql sink wants map[string]interface{}, got %T", out)
+	}
+	if len(row) == 0 {
+		return errors.New("presets: sql sink got an empty row")
+	}
+	cols := make([]string, 0, len(row))
+	for k := range row {
+		if !identRe.MatchString(k) {
+			return fmt.Errorf("presets: invalid column name %q", k)
+		}
+		cols = append(cols, k)
+	}
+	sort.Strings(cols)
+	args := make([]interface{}, len(cols))
+	marks := make([]string, len(cols))
+	for i, k := range cols {
+		args[i] = row[k]
+		if ss.Placeholder != nil {
+			marks[i] = ss.Placeholder(i + 1)
+		} else {
+			marks[i] = "?"
+		}
+	}
+	q := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", ss.table, strings.Join(cols, ", "), strings.Join(marks, ", "))
+	_, err := ss.db.Exec(q, args...)
+	return err
+}
+
+// Close 实现 handlers.Sink 接口。
+func (ss *SQLSink) Close() error {
+	return ss.db.Close()
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LaplaceNoise 给聚合结果（map[string]interface{}）的数值字段加上拉普拉斯噪声，实现 ε-差分隐私，不会修改原数据。
+// 噪声的尺度为 sensitivity / epsilon：sensitivity 为单个用户对该字段的最大影响，例如计数为 1。
+// 字段不存在或为 nil 时跳过。
+type LaplaceNoise struct {
+	fields []string
+	scale  float64
+
+	// Round 为 true 时把结果四舍五入为整数，适用于计数。
+	Round bool
+	// NonNegative 为 true 时把负数结果截为 0。
+	NonNegative bool
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewLaplaceNoise 新建差分隐私噪声处理器，epsilon 越小隐私保护越强、噪声越大。
+func NewLaplaceNoise(epsilon, sensitivity float64, fields ...string) *LaplaceNoise {
+	return &LaplaceNoise{
+		fields: fields,
+		scale:  sensitivity / epsilon,
+		rnd:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetSeed 设置随机数种子，用于复现结果。
+func (ln *LaplaceNoise) SetSeed(seed int64) {
+	ln.mu.Lock()
+	ln.rnd = rand.New(rand.NewSource(seed))
+	ln.mu.Unlock()
+}
+
+// sample 返回一个拉普拉斯分布的随机数。
+func (ln *LaplaceNoise) sample() float64 {
+	ln.mu.Lock()
+	u := ln.rnd.Float64() - 0.5
+	ln.mu.Unlock()
+	if u < 0 {
+		return ln.scale * math.Log(1+2*u)
+	}
+	return -ln.scale * math.Log(1-2*u)
+}
+
+// Handle 实现 Handler 接口。
+func (ln *LaplaceNoise) Handle(in interface{}) (interface{}, error) {
+	m, ok := in.(map[string]interface{})
+	if !ok {
+		return in, nil
+	}
+	for _, f := range ln.fields {
+		v, ok := fieldValue(m, f)
+		if !ok || v == nil {
+			continue
+		}
+		n, ok := normalizeValue(v).(float64)
+		if !ok {
+			return nil, fmt.Errorf("laplace noise: field %q: %T is not a number", f, v)
+		}
+		n += ln.sample()
+		if ln.Round {
+			n = math.Round(n)
+		}
+		if ln.NonNegative && n < 0 {
+			n = 0
+		}
+		m = setField(m, strings.Split(f, "."), n)
+	}
+	return m, nil
+}
+
+// KAnonymity 抑制人数过少的聚合结果：CountField 小于 k 的数据被丢弃，避免从小分组中识别出个人。
+// 设置了 Generalize 时不丢弃，而是把这些字段替换为 "*"，由后面的处理器合并泛化后的分组。
+type KAnonymity struct {
+	countField string
+	k          float64
+
+	// Generalize 不为空时，过小的分组中这些字段被替换为 "*" 后保留。
+	Generalize []string
+
+	suppressed int64
+}
+
+// NewKAnonymity 新建 k-匿名处理器，countField 为分组人数所在的字段。
+func NewKAnonymity(countField string, k int) *KAnonymity {
+	return &KAnonymity{countField: countField, k: float64(k)}
+}
+
+// Handle 实现 Handler 接口。
+func (ka *KAnonymity) Handle(in interface{}) (interface{}, error) {
+	m, ok := in.(map[string]interface{})
+	if !ok {
+		return in, nil
+	}
+	v, _ := fieldValue(m, ka.countField)
+	n, ok := normalizeValue(v).(float64)
+	if !ok {
+		return nil, fmt.Errorf("k-anonymity: count field %q: %T is not a number", ka.countField, v)
+	}
+	if n >= ka.k {
+		return in, nil
+	}
+	atomic.AddInt64(&ka.suppressed, 1)
+	if len(ka.Generalize) == 0 {
+		return None, nil
+	}
+	for _, f := range ka.Generalize {
+		m = setField(m, strings.Split(f, "."), "*")
+	}
+	return m, nil
+}
+
+// Suppressed 返回被抑制（丢弃或泛化）的数据条数。
+func (ka *KAnonymity) Suppressed() int64 {
+	return atomic.LoadInt64(&ka.suppressed)
+}
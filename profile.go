@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+)
+
+// ProfileEnv 未调用 SetProfile 时从该环境变量读取当前的配置名称。
+const ProfileEnv = "HANDLERS_PROFILE"
+
+// profileStage 只在指定的配置下生效的处理器，不生效时数据原样通过。
+type profileStage struct {
+	h        Handler
+	profiles []string
+	active   int32 // Run 开始时根据当前配置设置
+}
+
+// AddProfileHandler 添加只在指定配置（例如 "debug"）下生效的处理器，
+// 其他配置下数据原样通过。这样同一个处理链可以有多个变体，例如 debug 配置下插入跟踪和校验步骤。
+func (h *Handlers) AddProfileHandler(handler Handler, profiles ...string) {
+	h.AddHandler(&profileStage{h: handler, profiles: profiles})
+}
+
+// SetProfile 设置当前的配置名称，在下次 Run 时生效。为空时使用环境变量 HANDLERS_PROFILE。
+func (h *Handlers) SetProfile(profile string) {
+	h.Lock()
+	h.profile = profile
+	h.Unlock()
+}
+
+// applyProfile 根据当前配置启用或禁用 AddProfileHandler 添加的处理器。
+func (h *Handlers) applyProfile() {
+	h.RLock()
+	profile := h.profile
+	h.RUnlock()
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
+	}
+	if h.handlers == nil {
+		return
+	}
+	h.handlers.RLock()
+	defer h.handlers.RUnlock()
+	for e := h.handlers.Front(); e != nil; e = e.Next() {
+		ps, ok := e.Value.(*profileStage)
+		if !ok {
+			continue
+		}
+		var active int32
+		for _, p := range ps.profiles {
+			if p == profile {
+				active = 1
+				break
+			}
+		}
+		atomic.StoreInt32(&ps.active, active)
+	}
+}
+
+// eachHandler 依次对处理链中的处理器调用 fn，AddProfileHandler 等包装过的处理器会被展开。
+func (h *Handlers) eachHandler(fn func(v Handler)) {
+	if h.handlers == nil {
+		return
+	}
+	h.handlers.RLock()
+	defer h.handlers.RUnlock()
+	for e := h.handlers.Front(); e != nil; e = e.Next() {
+		fn(unwrapHandler(e.Value.(Handler)))
+	}
+}
+
+func (ps *profileStage) unwrap() Handler { return ps.h }
+
+func (ps *profileStage) isActive() bool {
+	return atomic.LoadInt32(&ps.active) == 1
+}
+
+// Handle 实现 Handler 接口。
+func (ps *profileStage) Handle(in interface{}) (interface{}, error) {
+	if !ps.isActive() {
+		return in, nil
+	}
+	return ps.h.Handle(in)
+}
+
+// HandleMarker 实现 MarkerHandler 接口。
+func (ps *profileStage) HandleMarker(m Marker) (interface{}, error) {
+	if mh, ok := ps.h.(MarkerHandler); ok && ps.isActive() {
+		return mh.HandleMarker(m)
+	}
+	return nil, nil
+}
+
+// Flush 实现 Flusher 接口。
+func (ps *profileStage) Flush() error {
+	if f, ok := ps.h.(Flusher); ok && ps.isActive() {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Warmup 实现 Warmer 接口。
+func (ps *profileStage) Warmup(ctx context.Context) error {
+	if w, ok := ps.h.(Warmer); ok && ps.isActive() {
+		return w.Warmup(ctx)
+	}
+	return nil
+}
+
+// SetSideEmitter 实现 SideOutputHandler 接口。
+func (ps *profileStage) SetSideEmitter(emit SideEmitter) {
+	if sh, ok := ps.h.(SideOutputHandler); ok {
+		sh.SetSideEmitter(emit)
+	}
+}
+
+// Health 实现 HealthChecker 接口。
+func (ps *profileStage) Health() error {
+	if hc, ok := ps.h.(HealthChecker); ok && ps.isActive() {
+		return hc.Health()
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Progress 文件源的读取进度。
+type Progress struct {
+	File      string        `json:"file"`       // 正在读取的文件
+	FileIndex int           `json:"file_index"` // 正在读取的文件的序号，从 0 开始
+	Files     int           `json:"files"`      // 文件总数
+	Read      int64         `json:"read"`       // 已经读取的字节数
+	Size      int64         `json:"size"`       // 需要读取的总字节数
+	Fraction  float64       `json:"fraction"`   // 完成的比例，0 到 1
+	Elapsed   time.Duration `json:"elapsed"`    // 开始读取以来的时间
+	ETA       time.Duration `json:"eta"`        // 按目前的速度估计的剩余时间，无法估计时为 -1
+}
+
+// estimate 根据 begin（UnixNano，0 表示尚未开始读取）计算 Fraction、Elapsed 和 ETA。
+func (p *Progress) estimate(begin int64) {
+	p.ETA = -1
+	if p.Size > 0 {
+		p.Fraction = float64(p.Read) / float64(p.Size)
+		if p.Fraction > 1 {
+			p.Fraction = 1
+		}
+	} else if p.Files > 0 && p.FileIndex >= p.Files {
+		p.Fraction = 1
+	}
+	if begin == 0 {
+		return
+	}
+	p.Elapsed = time.Since(time.Unix(0, begin))
+	switch {
+	case p.Fraction >= 1:
+		p.ETA = 0
+	case p.Read > 0:
+		p.ETA = time.Duration(float64(p.Elapsed) * float64(p.Size-p.Read) / float64(p.Read))
+	}
+}
+
+// progressReporter 限制 OnProgress 回调的频率。
+type progressReporter struct {
+	fn    func(Progress)
+	every time.Duration
+	last  time.Time
+}
+
+// report 距离上次回调超过 every 或 force 时调用回调，只在 Next 所在的 goroutine 中调用。
+func (pr *progressReporter) report(progress func() Progress, force bool) {
+	if pr.fn == nil {
+		return
+	}
+	now := time.Now()
+	if !force && now.Sub(pr.last) < pr.every {
+		return
+	}
+	pr.last = now
+	pr.fn(progress())
+}
+
+// Progress 返回读取进度，可以在其他 goroutine 中调用。
+func (fs *FileSource) Progress() Progress {
+	p := Progress{
+		File:  fs.path,
+		Files: 1,
+		Read:  atomic.LoadInt64(&fs.read) - fs.start,
+		Size:  fs.Size(),
+	}
+	p.estimate(atomic.LoadInt64(&fs.begin))
+	return p
+}
+
+// OnProgress 设置进度回调，读取过程中最多每隔 every 调用一次，读完或出错时也会调用。
+// 回调在调用 Next 的 goroutine 中执行，需要在 Run 之前设置。
+func (fs *FileSource) OnProgress(every time.Duration, fn func(Progress)) {
+	fs.progress = progressReporter{fn: fn, every: every}
+}
+
+// Progress 返回所有文件的读取进度，可以在其他 goroutine 中调用。
+func (mfs *MultiFileSrc) Progress() Progress {
+	i := int(atomic.LoadInt32(&mfs.cur))
+	p := Progress{FileIndex: i, Files: len(mfs.src)}
+	for j, src := range mfs.src {
+		p.Size += src.Size()
+		switch {
+		case j < i:
+			p.Read += src.Size()
+		case j == i:
+			p.File = src.path
+			p.Read += atomic.LoadInt64(&src.read) - src.start
+		}
+	}
+	p.estimate(atomic.LoadInt64(&mfs.begin))
+	return p
+}
+
+// OnProgress 设置进度回调，同 FileSource.OnProgress，回调中为所有文件的进度。
+func (mfs *MultiFileSrc) OnProgress(every time.Duration, fn func(Progress)) {
+	mfs.progress = progressReporter{fn: fn, every: every}
+}
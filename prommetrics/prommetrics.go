@@ -0,0 +1,147 @@
+// Package prommetrics 把 handlers 的运行状态导出为 Prometheus 指标。
+//
+//	c := prommetrics.NewCollector(h, "etl")
+//	h.Use(c.Middleware())
+//	prometheus.MustRegister(c)
+//
+// prommetrics 是单独的模块（见 prommetrics/go.mod），handlers 本身不依赖 Prometheus 客户端。
+package prommetrics
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/qn-zyc/handlers"
+)
+
+// 状态指标的 state 标签。
+var stateNames = map[int32]string{
+	handlers.StatusInit:     "init",
+	handlers.StatusRunning:  "running",
+	handlers.StatusStop:     "stop",
+	handlers.StatusDraining: "draining",
+	handlers.StatusFailed:   "failed",
+	handlers.StatusPaused:   "paused",
+}
+
+// Collector 实现 prometheus.Collector。源的读取量、源的数量和状态在采集时读取，
+// 处理器的耗时、出错和丢弃次数由 Middleware 记录。
+type Collector struct {
+	h *handlers.Handlers
+
+	items   *prometheus.Desc
+	bytes   *prometheus.Desc
+	sources *prometheus.Desc
+	state   *prometheus.Desc
+
+	latency *prometheus.HistogramVec
+	errors  *prometheus.CounterVec
+	skipped *prometheus.CounterVec
+}
+
+// NewCollector 新建 h 的指标采集器，指标名以 namespace 为前缀。
+func NewCollector(h *handlers.Handlers, namespace string) *Collector {
+	return &Collector{
+		h: h,
+		items: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "source_items_total"),
+			"Items read from each source, including the ones being processed.", []string{"source"}, nil),
+		bytes: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "source_bytes_total"),
+			"Bytes read from each source, including the ones being processed.", []string{"source"}, nil),
+		sources: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "sources"),
+			"Number of pending and done sources.", []string{"status"}, nil),
+		state: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "state"),
+			"Current state of the pipeline, 1 for the active state.", []string{"state"}, nil),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "handler_duration_seconds",
+			Help:      "Time spent in each handler per item.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"handler"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "handler_errors_total",
+			Help:      "Errors returned by each handler.",
+		}, []string{"handler"}),
+		skipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "handler_skipped_total",
+			Help:      "Items dropped by each handler with ErrSkip or None.",
+		}, []string{"handler"}),
+	}
+}
+
+// Middleware 返回记录处理器耗时、出错和丢弃次数的中间件，需要通过 Handlers.Use 添加。
+func (c *Collector) Middleware() handlers.Middleware {
+	return func(next handlers.Handler) handlers.Handler {
+		name := handlerName(next)
+		latency := c.latency.WithLabelValues(name)
+		errs := c.errors.WithLabelValues(name)
+		skipped := c.skipped.WithLabelValues(name)
+		return handlers.HandlerFunc(func(in interface{}) (interface{}, error) {
+			start := time.Now()
+			out, err := next.Handle(in)
+			latency.Observe(time.Since(start).Seconds())
+			switch {
+			case errors.Is(err, handlers.ErrSkip):
+				skipped.Inc()
+			case err != nil:
+				errs.Inc()
+			case out == handlers.None:
+				skipped.Inc()
+			}
+			return out, err
+		})
+	}
+}
+
+// Describe 实现 prometheus.Collector 接口。
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.items
+	ch <- c.bytes
+	ch <- c.sources
+	ch <- c.state
+	c.latency.Describe(ch)
+	c.errors.Describe(ch)
+	c.skipped.Describe(ch)
+}
+
+// Collect 实现 prometheus.Collector 接口。
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	items := make(map[string]int64)
+	bytes := make(map[string]int64)
+	for _, st := range c.h.SourceStats() {
+		name := st.Name
+		if name == "" {
+			name = fmt.Sprintf("%T", st.Source)
+		}
+		items[name] += st.Items
+		bytes[name] += st.Bytes
+	}
+	for name, n := range items {
+		ch <- prometheus.MustNewConstMetric(c.items, prometheus.CounterValue, float64(n), name)
+		ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.CounterValue, float64(bytes[name]), name)
+	}
+	ch <- prometheus.MustNewConstMetric(c.sources, prometheus.GaugeValue, float64(len(c.h.QueuedSources())), "pending")
+	ch <- prometheus.MustNewConstMetric(c.sources, prometheus.GaugeValue, float64(len(c.h.CompletedSources())), "done")
+	cur := c.h.State()
+	for state, name := range stateNames {
+		v := 0.0
+		if state == cur {
+			v = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.state, prometheus.GaugeValue, v, name)
+	}
+	c.latency.Collect(ch)
+	c.errors.Collect(ch)
+	c.skipped.Collect(ch)
+}
+
+// handlerName 返回处理器的标签值。
+func handlerName(h handlers.Handler) string {
+	if s, ok := h.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", h)
+}
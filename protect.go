@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// SecretProvider 按名称提供密钥等敏感配置，例如从环境变量、文件或密钥管理服务读取。实现必须是并发安全的。
+type SecretProvider interface {
+	Secret(name string) ([]byte, error)
+}
+
+// SecretFunc 函数形式的 SecretProvider。
+type SecretFunc func(name string) ([]byte, error)
+
+// Secret 实现 SecretProvider 接口。
+func (f SecretFunc) Secret(name string) ([]byte, error) { return f(name) }
+
+// EnvSecrets 从环境变量读取密钥，变量名为 Prefix 加上名称。
+type EnvSecrets struct {
+	Prefix string
+}
+
+// Secret 实现 SecretProvider 接口，变量不存在或为空时返回错误。
+func (es EnvSecrets) Secret(name string) ([]byte, error) {
+	v := os.Getenv(es.Prefix + name)
+	if v == "" {
+		return nil, fmt.Errorf("secret %s%s not set", es.Prefix, name)
+	}
+	return []byte(v), nil
+}
+
+// ProtectMode 字段的保护方式。
+type ProtectMode int
+
+const (
+	ProtectEncrypt     ProtectMode = iota // AES-GCM 加密，结果为 "enc:" 加 base64，可以用 Decrypt 还原
+	ProtectToken                          // HMAC-SHA256 令牌化，相同的值得到相同的令牌，不可还原
+	ProtectFormatToken                    // 保留格式的令牌化：数字仍为数字，字母仍为同样大小写的字母，其他字符不变，不可还原
+)
+
+// encPrefix 加密结果的前缀。
+const encPrefix = "enc:"
+
+// FieldProtector 加密或令牌化 map[string]interface{} 数据中的敏感字段，不会修改原数据。
+// 密钥在预热或第一次使用时从 SecretProvider 读取，任意长度的密钥都会经过 SHA-256 派生。
+// 不是字符串的值先按 fmt.Sprint 转换为字符串，字段不存在或为 nil 时跳过。
+type FieldProtector struct {
+	secrets SecretProvider
+	keyName string
+	mode    ProtectMode
+	fields  []string
+
+	once sync.Once
+	key  []byte
+	aead cipher.AEAD
+	err  error
+}
+
+// NewFieldProtector 新建字段保护处理器，keyName 为 SecretProvider 中密钥的名称。
+func NewFieldProtector(secrets SecretProvider, keyName string, mode ProtectMode, fields ...string) *FieldProtector {
+	return &FieldProtector{secrets: secrets, keyName: keyName, mode: mode, fields: fields}
+}
+
+// init 读取密钥。
+func (fp *FieldProtector) init() error {
+	fp.once.Do(func() {
+		secret, err := fp.secrets.Secret(fp.keyName)
+		if err != nil {
+			fp.err = fmt.Errorf("field protector: %v", err)
+			return
+		}
+		sum := sha256.Sum256(secret)
+		fp.key = sum[:]
+		block, err := aes.NewCipher(fp.key)
+		if err == nil {
+			fp.aead, err = cipher.NewGCM(block)
+		}
+		fp.err = err
+	})
+	return fp.err
+}
+
+// Warmup 实现 Warmer 接口，提前读取密钥。
+func (fp *FieldProtector) Warmup(ctx context.Context) error {
+	return fp.init()
+}
+
+// Handle 实现 Handler 接口。
+func (fp *FieldProtector) Handle(in interface{}) (interface{}, error) {
+	m, ok := in.(map[string]interface{})
+	if !ok {
+		return in, nil
+	}
+	if err := fp.init(); err != nil {
+		return nil, err
+	}
+	for _, f := range fp.fields {
+		v, ok := fieldValue(m, f)
+		if !ok || v == nil {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			s = fmt.Sprint(v)
+		}
+		out, err := fp.protect(s)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %v", f, err)
+		}
+		m = setField(m, strings.Split(f, "."), out)
+	}
+	return m, nil
+}
+
+func (fp *FieldProtector) protect(s string) (string, error) {
+	switch fp.mode {
+	case ProtectToken:
+		mac := hmac.New(sha256.New, fp.key)
+		mac.Write([]byte(s))
+		return hex.EncodeToString(mac.Sum(nil)), nil
+	case ProtectFormatToken:
+		return fp.formatToken(s), nil
+	}
+	nonce := make([]byte, fp.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := fp.aead.Seal(nonce, nonce, []byte(s), nil)
+	return encPrefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// formatToken 用 HMAC 派生的字节流替换数字和字母，保留长度、字符类型和大小写。
+func (fp *FieldProtector) formatToken(s string) string {
+	mac := hmac.New(sha256.New, fp.key)
+	mac.Write([]byte(s))
+	stream := mac.Sum(nil)
+	var b strings.Builder
+	for i, r := range []rune(s) {
+		if i > 0 && i%len(stream) == 0 { // 长字符串继续派生
+			mac.Reset()
+			mac.Write(stream)
+			stream = mac.Sum(nil)
+		}
+		k := int(stream[i%len(stream)])
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune('0' + rune(k%10))
+		case r >= 'a' && r <= 'z':
+			b.WriteRune('a' + rune(k%26))
+		case r >= 'A' && r <= 'Z':
+			b.WriteRune('A' + rune(k%26))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune('x')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Decrypt 还原 ProtectEncrypt 加密的值。
+func (fp *FieldProtector) Decrypt(s string) (string, error) {
+	if err := fp.init(); err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(s, encPrefix) {
+		return "", fmt.Errorf("field protector: value is not encrypted")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s[len(encPrefix):])
+	if err != nil {
+		return "", fmt.Errorf("field protector: %v", err)
+	}
+	n := fp.aead.NonceSize()
+	if len(b) < n {
+		return "", fmt.Errorf("field protector: ciphertext too short")
+	}
+	plain, err := fp.aead.Open(nil, b[:n], b[n:], nil)
+	if err != nil {
+		return "", fmt.Errorf("field protector: %v", err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+)
+
+// RateAction 超过限速时的处理方式。
+type RateAction int
+
+// 超过限速时的处理方式
+const (
+	RateDrop      RateAction = iota // 丢弃
+	RateDelay                       // 等待直到有可用的令牌
+	RateSideRoute                   // 发送到旁路输出
+)
+
+// tokenBucket 单个键的令牌桶。
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// KeyedRateLimiter 按键限速的处理器，每个键（例如用户、IP）有自己的令牌桶，
+// 与整个处理链的限速互不影响。
+type KeyedRateLimiter struct {
+	key   func(in interface{}) string
+	rate  float64 // 每秒产生的令牌数
+	burst float64 // 令牌桶容量
+	emit  SideEmitter
+
+	// Action 超过限速时的处理方式，默认为 RateDrop。
+	Action RateAction
+	// SideOutput Action 为 RateSideRoute 时发送到的旁路输出名称。
+	SideOutput string
+	// Store 保存令牌桶，默认为不限容量的 MemStore，需在第一次调用 Handle 前设置。
+	Store Store
+}
+
+// NewKeyedRateLimiter 新建按键限速的处理器，key 从数据中提取限速的键，
+// 每个键每秒最多通过 rate 条数据，允许 burst 条的突发。rate <= 0 时不限速。
+func NewKeyedRateLimiter(key func(in interface{}) string, rate float64, burst int) *KeyedRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &KeyedRateLimiter{
+		key:   key,
+		rate:  rate,
+		burst: float64(burst),
+		Store: NewMemStore(0),
+	}
+}
+
+// SetSideEmitter 实现 SideOutputHandler 接口。
+func (krl *KeyedRateLimiter) SetSideEmitter(emit SideEmitter) {
+	krl.emit = emit
+}
+
+// StateStore 实现 StatefulHandler 接口。
+func (krl *KeyedRateLimiter) StateStore() Store {
+	return krl.Store
+}
+
+// SetStateStore 实现 StatefulHandler 接口。
+func (krl *KeyedRateLimiter) SetStateStore(store Store) {
+	krl.Store = store
+}
+
+// Handle 实现 Handler 接口。
+func (krl *KeyedRateLimiter) Handle(in interface{}) (interface{}, error) {
+	if krl.rate <= 0 {
+		return in, nil
+	}
+	wait := krl.take(krl.key(in), krl.Action == RateDelay)
+	if wait <= 0 {
+		return in, nil
+	}
+	switch krl.Action {
+	case RateDelay:
+		time.Sleep(wait)
+		return in, nil
+	case RateSideRoute:
+		if krl.emit == nil {
+			return nil, fmt.Errorf("rate limiter: side output %q not bound", krl.SideOutput)
+		}
+		if err := krl.emit(krl.SideOutput, in); err != nil {
+			return nil, err
+		}
+	}
+	return None, nil
+}
+
+// take 从 key 的令牌桶中取一个令牌，返回需要等待的时间，<= 0 表示取到了令牌。
+// reserve 为 true 时即使没有令牌也预支一个，调用方等待返回的时间后即可通过。
+func (krl *KeyedRateLimiter) take(key string, reserve bool) time.Duration {
+	var wait time.Duration
+	// 令牌桶装满后就和新建的一样，因此可以在那之后过期。
+	ttl := time.Duration(krl.burst/krl.rate*float64(time.Second)) + time.Second
+	krl.Store.Update(key, ttl, func(old interface{}, ok bool) interface{} {
+		now := time.Now()
+		b := tokenBucket{tokens: krl.burst, last: now}
+		if ok {
+			b = old.(tokenBucket)
+			b.tokens += now.Sub(b.last).Seconds() * krl.rate
+			if b.tokens > krl.burst {
+				b.tokens = krl.burst
+			}
+			b.last = now
+		}
+		if b.tokens >= 1 {
+			b.tokens--
+			return b
+		}
+		wait = time.Duration((1 - b.tokens) / krl.rate * float64(time.Second))
+		if reserve {
+			b.tokens--
+		}
+		return b
+	})
+	return wait
+}
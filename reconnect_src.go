@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// DialFunc 建立连接并返回对应的数据源。
+type DialFunc func() (Source, error)
+
+// ReconnectSource 断线自动重连的数据源，适用于 socket、消息队列等流式数据源。
+// 底层数据源返回非致命错误时认为连接已断开，关闭后重新调用 DialFunc 建立连接。
+// 断线期间的策略是阻塞：Next 不返回数据，直到重连成功（或 GiveUp 时放弃），
+// 不在本地缓存或丢弃数据。数据源是拉取的，断线期间的数据留在对端（消息队列、发送方），
+// 需要缓存或丢弃时应由底层数据源或对端实现，例如消息队列的消费位置、发送方的缓冲区。
+type ReconnectSource struct {
+	dial DialFunc
+	src  Source
+
+	// Backoff 重连的退避策略，默认为 NewBackoff(100ms, 30s)。
+	Backoff BackoffFunc
+	// IsFatal 判断错误是否为致命错误，致命错误直接返回不再重连，默认只有 io.EOF。
+	IsFatal func(err error) bool
+	// MaxDowntime 断线超过该时长时调用 OnDowntime 报警，为 0 表示不限制。
+	MaxDowntime time.Duration
+	// OnDowntime 断线超过 MaxDowntime 时调用一次，err 为最后一次连接失败的原因。
+	OnDowntime func(downtime time.Duration, err error)
+	// GiveUp 为 true 时断线超过 MaxDowntime 后不再重连，Next 返回最后一次的错误；
+	// 为 false 时一直阻塞重连。
+	GiveUp bool
+
+	downSince time.Time // 断线开始的时间，连接正常时为零值
+	alarmed   bool      // 本次断线是否已经报警
+}
+
+// NewReconnectSrc 新建自动重连的数据源，首次调用 Next 时才建立连接。
+func NewReconnectSrc(dial DialFunc) *ReconnectSource {
+	return &ReconnectSource{dial: dial}
+}
+
+// Next 实现 Source 接口。
+func (rs *ReconnectSource) Next() (data interface{}, err error) {
+	for {
+		if rs.src == nil {
+			if err = rs.connect(); err != nil {
+				return nil, err
+			}
+		}
+		data, err = rs.src.Next()
+		if err == nil || rs.fatal(err) {
+			return data, err
+		}
+		rs.disconnect()
+		// 断线前读到的数据先返回，下次调用再重连。
+		if data != nil {
+			return data, nil
+		}
+	}
+}
+
+func (rs *ReconnectSource) fatal(err error) bool {
+	if rs.IsFatal != nil {
+		return rs.IsFatal(err)
+	}
+	return err == io.EOF
+}
+
+func (rs *ReconnectSource) backoff(attempt int) time.Duration {
+	if rs.Backoff == nil {
+		rs.Backoff = NewBackoff(100*time.Millisecond, 30*time.Second)
+	}
+	return rs.Backoff(attempt)
+}
+
+// connect 一直重试直到连接成功，或者断线超时且 GiveUp 为 true。
+func (rs *ReconnectSource) connect() error {
+	for attempt := 0; ; attempt++ {
+		src, err := rs.dial()
+		if err == nil {
+			rs.src = src
+			rs.downSince = time.Time{}
+			rs.alarmed = false
+			return nil
+		}
+		if rs.downSince.IsZero() {
+			rs.downSince = time.Now()
+		}
+		if down := time.Since(rs.downSince); rs.MaxDowntime > 0 && down > rs.MaxDowntime {
+			if !rs.alarmed && rs.OnDowntime != nil {
+				rs.OnDowntime(down, err)
+			}
+			rs.alarmed = true
+			if rs.GiveUp {
+				return err
+			}
+		}
+		time.Sleep(rs.backoff(attempt))
+	}
+}
+
+func (rs *ReconnectSource) disconnect() {
+	if c, ok := rs.src.(io.Closer); ok {
+		c.Close()
+	}
+	rs.src = nil
+	rs.downSince = time.Now()
+}
+
+// Health 实现 HealthChecker 接口。
+func (rs *ReconnectSource) Health() error {
+	if rs.src == nil {
+		if rs.alarmed {
+			return errors.New("reconnect source: downtime exceeded")
+		}
+		return nil
+	}
+	if hc, ok := rs.src.(HealthChecker); ok {
+		return hc.Health()
+	}
+	return nil
+}
+
+// Close 关闭当前连接。
+func (rs *ReconnectSource) Close() error {
+	if rs.src == nil {
+		return nil
+	}
+	var err error
+	if c, ok := rs.src.(io.Closer); ok {
+		err = c.Close()
+	}
+	rs.src = nil
+	return err
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Rejecter 记录处理失败的数据。设置后处理器返回错误时不再中止数据源，
+// 而是把从数据源读到的原始数据交给 Reject，然后继续处理下一条。
+// Reject 返回错误时数据源被中止。异步处理器和控制标记产生的错误不经过 Rejecter。
+type Rejecter interface {
+	// Reject 记录一条处理失败的数据，line 为数据在源中的行号（或序号）。
+	Reject(src Source, line int64, item interface{}, err error) error
+}
+
+// SetRejecter 设置处理失败的数据的记录器，为 nil 时处理器返回错误会中止数据源（默认）。
+func (h *Handlers) SetRejecter(r Rejecter) {
+	h.Lock()
+	h.rejecter = r
+	h.Unlock()
+}
+
+// reject 把处理失败的数据交给 r。
+func (h *Handlers) reject(r Rejecter, ent *srcEntry, item interface{}, err error) error {
+	line := atomic.LoadInt64(&ent.items)
+	if ls, ok := ent.src.(lineSource); ok {
+		line = ls.Line()
+	}
+	return r.Reject(ent.src, line, item, err)
+}
+
+// ErrorSidecar 为每个输入文件在旁边生成一个 .errors 文件，每行记录一条处理失败的数据：
+//
+//	行号<TAB>原因
+//
+// 原因中的换行和制表符会被转义。只处理实现了 DescribedSource 的数据源，其他数据源的错误被忽略。
+// 同一时间只打开一个 .errors 文件，处理完成后需调用 Close。
+// .errors 文件在 Close 之前第一次打开时清空原有内容，再次打开时追加写入，所以重新运行不会留下上次的记录。
+type ErrorSidecar struct {
+	mu     sync.Mutex
+	name   string          // 当前打开的 .errors 文件对应的源
+	file   *os.File        // 当前打开的 .errors 文件
+	opened map[string]bool // Close 之前打开过的源
+}
+
+// NewErrorSidecar 新建 .errors 文件记录器。
+func NewErrorSidecar() *ErrorSidecar {
+	return &ErrorSidecar{}
+}
+
+var reasonEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r", "\t", "\\t")
+
+// Reject 实现 Rejecter 接口。
+func (es *ErrorSidecar) Reject(src Source, line int64, item interface{}, err error) error {
+	ds, ok := src.(DescribedSource)
+	if !ok {
+		return nil
+	}
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if err := es.open(ds.Name()); err != nil {
+		return err
+	}
+	_, werr := fmt.Fprintf(es.file, "%d\t%s\n", line, reasonEscaper.Replace(err.Error()))
+	return werr
+}
+
+// open 打开 name 对应的 .errors 文件，调用方需持有 es.mu。
+func (es *ErrorSidecar) open(name string) error {
+	if es.file != nil && es.name == name {
+		return nil
+	}
+	if err := es.close(); err != nil {
+		return err
+	}
+	mode := os.O_TRUNC
+	if es.opened[name] {
+		mode = os.O_APPEND
+	}
+	file, err := os.OpenFile(name+".errors", os.O_CREATE|os.O_WRONLY|mode, 0644)
+	if err != nil {
+		return err
+	}
+	if es.opened == nil {
+		es.opened = make(map[string]bool)
+	}
+	es.name, es.file, es.opened[name] = name, file, true
+	return nil
+}
+
+// close 调用方需持有 es.mu。
+func (es *ErrorSidecar) close() error {
+	if es.file == nil {
+		return nil
+	}
+	err := es.file.Close()
+	es.file = nil
+	return err
+}
+
+// Close 关闭当前打开的 .errors 文件，之后再打开的 .errors 文件会重新清空。
+func (es *ErrorSidecar) Close() error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	es.opened = nil
+	return es.close()
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// RejectRecord 拒绝文件中的一条记录。
+type RejectRecord struct {
+	Source string `json:"source,omitempty"` // 数据源的名称
+	Line   int64  `json:"line"`             // 在数据源中的行号（或序号）
+	Error  string `json:"error"`            // 处理失败的原因
+	// 原始数据按类型保存在以下字段之一：string 原样保存，[]byte 以 base64 保存，其他类型以 JSON 保存。
+	Payload      *string         `json:"payload,omitempty"`
+	PayloadBytes []byte          `json:"payload_bytes,omitempty"`
+	PayloadJSON  json.RawMessage `json:"payload_json,omitempty"`
+}
+
+// RejectFile 把处理失败的数据连同原始内容写入拒绝文件，每行一个 JSON 格式的 RejectRecord。
+// 拒绝文件可以用 NewRejectFileSrc 作为数据源重新处理，修正后的数据无需再做转换。
+type RejectFile struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+// NewRejectFile 新建拒绝文件，文件已存在时追加写入。
+func NewRejectFile(path string) (*RejectFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &RejectFile{file: file, w: bufio.NewWriter(file)}, nil
+}
+
+// Reject 实现 Rejecter 接口。
+func (rf *RejectFile) Reject(src Source, line int64, item interface{}, err error) error {
+	rec := RejectRecord{Line: line, Error: err.Error()}
+	if ds, ok := src.(DescribedSource); ok {
+		rec.Source = ds.Name()
+	}
+	switch v := item.(type) {
+	case string:
+		rec.Payload = &v
+	case []byte:
+		rec.PayloadBytes = v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("reject file: encode payload: %v", err)
+		}
+		rec.PayloadJSON = b
+	}
+	b, jerr := json.Marshal(rec)
+	if jerr != nil {
+		return jerr
+	}
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if _, werr := rf.w.Write(b); werr != nil {
+		return werr
+	}
+	return rf.w.WriteByte('\n')
+}
+
+// Flush 实现 Flusher 接口。
+func (rf *RejectFile) Flush() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.w.Flush()
+}
+
+// Close 写入缓存的数据并关闭文件。
+func (rf *RejectFile) Close() error {
+	if err := rf.Flush(); err != nil {
+		rf.file.Close()
+		return err
+	}
+	return rf.file.Close()
+}
+
+// RejectFileSource 读取拒绝文件，按原来的类型返回每条记录的原始数据。
+type RejectFileSource struct {
+	fs   *FileSource
+	last RejectRecord
+}
+
+// NewRejectFileSrc 新建拒绝文件源。
+func NewRejectFileSrc(path string) (*RejectFileSource, error) {
+	fs, err := NewFileSrc(path)
+	if err != nil {
+		return nil, err
+	}
+	return &RejectFileSource{fs: fs}, nil
+}
+
+// Next 实现 Source 接口，返回的数据与写入拒绝文件前的原始数据类型相同，JSON 保存的数据解码为 interface{}。
+func (rfs *RejectFileSource) Next() (data interface{}, err error) {
+	for {
+		line, err := rfs.fs.Next()
+		text := strings.TrimSpace(line.(string))
+		if text == "" {
+			if err != nil {
+				return nil, err
+			}
+			continue // 跳过空行
+		}
+		var rec RejectRecord
+		if jerr := json.Unmarshal([]byte(text), &rec); jerr != nil {
+			return nil, fmt.Errorf("%s at line %d: %v", rfs.fs.Name(), rfs.fs.Line(), jerr)
+		}
+		rfs.last = rec
+		switch {
+		case rec.Payload != nil:
+			data = *rec.Payload
+		case rec.PayloadBytes != nil:
+			data = rec.PayloadBytes
+		case rec.PayloadJSON != nil:
+			if jerr := json.Unmarshal(rec.PayloadJSON, &data); jerr != nil {
+				return nil, fmt.Errorf("%s at line %d: %v", rfs.fs.Name(), rfs.fs.Line(), jerr)
+			}
+		}
+		return data, err
+	}
+}
+
+// Record 返回最近一次 Next 读取的记录，可用于查看原来的出错位置和原因。
+func (rfs *RejectFileSource) Record() RejectRecord {
+	return rfs.last
+}
+
+// Name 实现 DescribedSource 接口。
+func (rfs *RejectFileSource) Name() string { return rfs.fs.Name() }
+
+// Size 实现 DescribedSource 接口。
+func (rfs *RejectFileSource) Size() int64 { return rfs.fs.Size() }
+
+// EstimatedItems 实现 DescribedSource 接口。
+func (rfs *RejectFileSource) EstimatedItems() int64 { return rfs.fs.EstimatedItems() }
+
+// Line 返回最近一次 Next 读取的记录在拒绝文件中的行号。
+func (rfs *RejectFileSource) Line() int64 { return rfs.fs.Line() }
+
+// Close 关闭文件。
+func (rfs *RejectFileSource) Close() error { return rfs.fs.Close() }
@@ -0,0 +1,80 @@
+package handlers
+
+import "time"
+
+// ChainReport RunChainOn 的统计结果。
+type ChainReport struct {
+	Inputs  int // 输入的数据条数
+	Outputs int // 处理链最终输出的数据条数
+	Dropped int // 没有产生输出的输入条数
+	Failed  int // 处理出错的输入条数
+}
+
+// RunChainOn 在当前 goroutine 中用处理链依次处理 items，不使用数据源，便于对处理链做表格驱动测试。
+// outputs 为处理链最终输出的所有数据（Emit 会被展开），errs[i] 为处理 items[i] 时的错误。
+// 处理完所有数据后会向处理器发送 MarkerEndOfSource，由此输出的数据也包含在 outputs 中，
+// 产生的错误追加在 errs 的末尾。
+// 异步处理器会同步等待完成。
+func (h *Handlers) RunChainOn(items []interface{}) (outputs []interface{}, errs []error, report ChainReport) {
+	h.applyProfile()
+	h.applyMiddleware()
+	h.bindSideOutputs()
+	var chain, orig Chain // chain 为中间件包装后的处理器
+	if h.handlers != nil {
+		h.handlers.RLock()
+		for e := h.handlers.Front(); e != nil; e = e.Next() {
+			chain = append(chain, h.handlerAt(e))
+			orig = append(orig, e.Value.(Handler))
+		}
+		h.handlers.RUnlock()
+	}
+
+	collect := func(out interface{}) int {
+		switch v := out.(type) {
+		case Emit:
+			outputs = append(outputs, v...)
+			return len(v)
+		case dropped:
+			return 0
+		}
+		outputs = append(outputs, out)
+		return 1
+	}
+
+	errs = make([]error, len(items))
+	report.Inputs = len(items)
+	for i, item := range items {
+		out, err := chain.Handle(item)
+		if err != nil {
+			errs[i] = err
+			report.Failed++
+			continue
+		}
+		if collect(out) == 0 {
+			report.Dropped++
+		}
+	}
+
+	m := Marker{Kind: MarkerEndOfSource, Time: time.Now()}
+	for i, handler := range orig {
+		hd, mh, ok := markerTarget(handler, chain[i])
+		if !ok {
+			continue
+		}
+		out, err := callMarker(hd, mh, m)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if out == nil {
+			continue
+		}
+		if out, err = chain.emitFrom(i+1, out); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		collect(out)
+	}
+	report.Outputs = len(outputs)
+	return outputs, errs, report
+}
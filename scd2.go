@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// SCDOpKind SCD 操作的类型。
+type SCDOpKind int
+
+const (
+	SCDInsert SCDOpKind = iota // 插入新的当前行
+	SCDClose                   // 关闭旧的当前行（设置失效时间）
+)
+
+// SCDOp 缓慢变化维（Type 2）的一个操作，由后面的输出转换为对应的写入，例如 SQL 的 UPDATE 和 INSERT。
+type SCDOp struct {
+	Kind SCDOpKind
+	Key  string                 // KeyFields 组成的键
+	Row  map[string]interface{} // Insert 时为新行，Close 时为被关闭的旧行
+	At   time.Time              // 新行的生效时间，也是旧行的失效时间
+}
+
+// SCD2 按 Type 2 缓慢变化维的方式处理维度数据：KeyFields 相同的数据中 Tracked 字段发生变化时，
+// 输出关闭旧行和插入新行两个 SCDOp；首次出现时只输出插入；没有变化时丢弃。
+// Store 保存每个键的当前行，Run 之前可以用 Seed 载入目标表中已有的当前行。
+type SCD2 struct {
+	keyFields []string
+	tracked   []string
+	store     Store
+
+	// Now 返回操作的时间，为 nil 时使用 time.Now。
+	Now func() time.Time
+}
+
+// NewSCD2 新建 SCD2 处理器，数据为 map[string]interface{}，store 为 nil 时使用不限容量的 MemStore。
+func NewSCD2(keyFields, tracked []string, store Store) *SCD2 {
+	if store == nil {
+		store = NewMemStore(0)
+	}
+	return &SCD2{keyFields: keyFields, tracked: tracked, store: store}
+}
+
+// StateStore 实现 StatefulHandler 接口。
+func (s *SCD2) StateStore() Store {
+	return s.store
+}
+
+// SetStateStore 实现 StatefulHandler 接口。
+func (s *SCD2) SetStateStore(store Store) {
+	s.store = store
+}
+
+// Seed 载入已有的当前行。
+func (s *SCD2) Seed(rows ...map[string]interface{}) {
+	for _, row := range rows {
+		s.store.Set(groupKey(row, s.keyFields), row, 0)
+	}
+}
+
+// Handle 实现 Handler 接口，返回 Emit 或 None。
+func (s *SCD2) Handle(in interface{}) (interface{}, error) {
+	row, ok := in.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("scd2: expects map[string]interface{}, got %T", in)
+	}
+	key := groupKey(row, s.keyFields)
+	var old map[string]interface{}
+	changed := false
+	s.store.Update(key, 0, func(v interface{}, ok bool) interface{} {
+		if ok {
+			old = v.(map[string]interface{})
+			if !s.changed(old, row) {
+				return old
+			}
+		}
+		changed = true
+		return row
+	})
+	if !changed {
+		return None, nil
+	}
+	at := time.Now()
+	if s.Now != nil {
+		at = s.Now()
+	}
+	out := Emit{}
+	if old != nil {
+		out = append(out, SCDOp{Kind: SCDClose, Key: key, Row: old, At: at})
+	}
+	return append(out, SCDOp{Kind: SCDInsert, Key: key, Row: row, At: at}), nil
+}
+
+// changed 返回 Tracked 字段是否发生了变化，数字按数值比较。
+func (s *SCD2) changed(old, cur map[string]interface{}) bool {
+	for _, f := range s.tracked {
+		if !reflect.DeepEqual(normalizeValue(old[f]), normalizeValue(cur[f])) {
+			return true
+		}
+	}
+	return false
+}
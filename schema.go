@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldType 字段的类型。
+type FieldType string
+
+// 字段的类型
+const (
+	TypeAny    FieldType = ""       // 任意类型
+	TypeString FieldType = "string" // string 或 []byte
+	TypeNumber FieldType = "number" // 所有整数和浮点数
+	TypeBool   FieldType = "bool"
+	TypeObject FieldType = "object" // map 或结构体
+	TypeArray  FieldType = "array"  // 除 []byte 外的切片和数组
+	TypeNull   FieldType = "null"   // nil
+)
+
+// SchemaField 字段定义，Name 为按 . 分隔的路径。
+type SchemaField struct {
+	Name     string    `json:"name"`
+	Type     FieldType `json:"type,omitempty"`
+	Required bool      `json:"required,omitempty"`
+	Nullable bool      `json:"nullable,omitempty"` // 为 true 时允许值为 nil
+}
+
+// Schema 数据的结构定义。
+type Schema struct {
+	Fields []SchemaField `json:"fields"`
+	// Closed 为 true 时不允许出现未定义的顶层字段，只检查 map[string]interface{}。
+	Closed bool `json:"closed,omitempty"`
+}
+
+// Check 检查 item 是否符合 s，返回所有不符合的地方，符合时返回 nil。
+func (s *Schema) Check(item interface{}) []string {
+	var violations []string
+	for _, f := range s.Fields {
+		v, ok := fieldValue(item, f.Name)
+		if !ok {
+			if f.Required {
+				violations = append(violations, fmt.Sprintf("missing required field %q", f.Name))
+			}
+			continue
+		}
+		if v == nil {
+			if !f.Nullable && f.Type != TypeAny {
+				violations = append(violations, fmt.Sprintf("field %q is null, want %s", f.Name, f.Type))
+			}
+			continue
+		}
+		if t := typeOf(v); f.Type != TypeAny && t != f.Type {
+			violations = append(violations, fmt.Sprintf("field %q is %s, want %s", f.Name, t, f.Type))
+		}
+	}
+	if m, ok := item.(map[string]interface{}); ok && s.Closed {
+		known := make(map[string]bool, len(s.Fields))
+		for _, f := range s.Fields {
+			known[strings.SplitN(f.Name, ".", 2)[0]] = true
+		}
+		for k := range m {
+			if !known[k] {
+				violations = append(violations, fmt.Sprintf("unexpected field %q", k))
+			}
+		}
+	}
+	return violations
+}
+
+// fieldValue 按 . 分隔的路径取值，ok 表示字段存在（值可能为 nil）。
+func fieldValue(item interface{}, path string) (v interface{}, ok bool) {
+	names := strings.Split(path, ".")
+	v = item
+	for i, name := range names {
+		if m, isMap := v.(map[string]interface{}); isMap {
+			v, ok = m[name]
+		} else {
+			v = lookupField(v, name)
+			ok = v != nil
+		}
+		if !ok || (v == nil && i < len(names)-1) {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// typeOf 返回值的字段类型。
+func typeOf(v interface{}) FieldType {
+	if v == nil {
+		return TypeNull
+	}
+	if _, ok := v.([]byte); ok {
+		return TypeString
+	}
+	switch normalizeValue(v).(type) {
+	case float64:
+		return TypeNumber
+	case string:
+		return TypeString
+	case bool:
+		return TypeBool
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return TypeNull
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map, reflect.Struct:
+		return TypeObject
+	case reflect.Slice, reflect.Array:
+		return TypeArray
+	}
+	return TypeAny
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SchemaChange 与上次运行相比 Schema 的变化。
+type SchemaChange struct {
+	Field    string `json:"field"`
+	Kind     string `json:"kind"` // added, removed, type, nullable, optional, required
+	Detail   string `json:"detail,omitempty"`
+	Breaking bool   `json:"breaking"` // 是否可能破坏下游
+}
+
+// SchemaReport 推断出的 Schema 及其变化。
+type SchemaReport struct {
+	Name    string         `json:"name"`
+	Schema  Schema         `json:"schema"`
+	Changes []SchemaChange `json:"changes,omitempty"`
+}
+
+// fieldStat 一个字段的统计。
+type fieldStat struct {
+	seen  int64              // 出现的次数
+	null  bool               // 是否出现过 nil
+	types map[FieldType]bool // 出现过的非 nil 类型
+}
+
+// SchemaInferrer 根据流经的 map[string]interface{} 数据推断 Schema（字段、类型、是否可为空），
+// 嵌套的 map 按 . 分隔的路径展开。数据原样交给下一个处理器。
+// 运行结束时推断结果和与上次运行的差异汇总到 RunSummary.Schemas，调用 Save 保存供下次运行比较。
+type SchemaInferrer struct {
+	name string
+	path string
+	prev *Schema
+
+	mu     sync.Mutex
+	items  int64
+	fields map[string]*fieldStat
+}
+
+// NewSchemaInferrer 新建 Schema 推断处理器，path 为保存 Schema 的文件，存在时读取作为上次运行的 Schema。
+// path 为空时不与上次运行比较。
+func NewSchemaInferrer(name, path string) (*SchemaInferrer, error) {
+	si := &SchemaInferrer{name: name, path: path, fields: make(map[string]*fieldStat)}
+	if path == "" {
+		return si, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return si, nil
+		}
+		return nil, err
+	}
+	prev := &Schema{}
+	if err = json.Unmarshal(b, prev); err != nil {
+		return nil, fmt.Errorf("schema inferrer: read %s: %v", path, err)
+	}
+	si.prev = prev
+	return si, nil
+}
+
+// String 返回处理器名称。
+func (si *SchemaInferrer) String() string { return "SchemaInferrer(" + si.name + ")" }
+
+// Handle 实现 Handler 接口。
+func (si *SchemaInferrer) Handle(in interface{}) (interface{}, error) {
+	m, ok := in.(map[string]interface{})
+	if !ok {
+		return in, nil
+	}
+	si.mu.Lock()
+	si.items++
+	si.observe("", m)
+	si.mu.Unlock()
+	return in, nil
+}
+
+func (si *SchemaInferrer) observe(prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		name := prefix + k
+		fs := si.fields[name]
+		if fs == nil {
+			fs = &fieldStat{types: make(map[FieldType]bool)}
+			si.fields[name] = fs
+		}
+		fs.seen++
+		if v == nil {
+			fs.null = true
+			continue
+		}
+		fs.types[typeOf(v)] = true
+		if sub, ok := v.(map[string]interface{}); ok {
+			si.observe(name+".", sub)
+		}
+	}
+}
+
+// Schema 返回目前推断出的 Schema，字段按名称排序。
+// 每条数据中都出现的字段为必需字段；出现多种类型的字段类型为 TypeAny。
+// 嵌套字段只要求在其父字段出现时都出现。
+func (si *SchemaInferrer) Schema() Schema {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	s := Schema{}
+	for name, fs := range si.fields {
+		f := SchemaField{Name: name, Nullable: fs.null}
+		if len(fs.types) == 1 {
+			for t := range fs.types {
+				f.Type = t
+			}
+		}
+		parent := si.items
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			if ps := si.fields[name[:i]]; ps != nil {
+				parent = ps.seen
+				for t := range ps.types {
+					if t != TypeObject {
+						parent = -1 // 父字段不总是 object，无法判断是否必需
+					}
+				}
+			}
+		}
+		f.Required = fs.seen == parent
+		s.Fields = append(s.Fields, f)
+	}
+	sort.Slice(s.Fields, func(i, j int) bool { return s.Fields[i].Name < s.Fields[j].Name })
+	return s
+}
+
+// Report 返回推断出的 Schema 以及与上次运行相比的变化。
+func (si *SchemaInferrer) Report() SchemaReport {
+	cur := si.Schema()
+	r := SchemaReport{Name: si.name, Schema: cur}
+	if si.prev != nil {
+		r.Changes = DiffSchema(*si.prev, cur)
+	}
+	return r
+}
+
+// Save 把推断出的 Schema 保存到 path，下次运行时与之比较。
+func (si *SchemaInferrer) Save() error {
+	if si.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(si.Schema(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := si.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, si.path)
+}
+
+// DiffSchema 比较两个 Schema，返回 cur 相对 prev 的变化，按字段名排序。
+// 删除字段、修改类型、变为可为空、必需变为非必需都视为破坏性变化。
+func DiffSchema(prev, cur Schema) []SchemaChange {
+	old := make(map[string]SchemaField, len(prev.Fields))
+	for _, f := range prev.Fields {
+		old[f.Name] = f
+	}
+	var changes []SchemaChange
+	seen := make(map[string]bool, len(cur.Fields))
+	for _, f := range cur.Fields {
+		seen[f.Name] = true
+		o, ok := old[f.Name]
+		if !ok {
+			changes = append(changes, SchemaChange{Field: f.Name, Kind: "added", Detail: string(f.Type)})
+			continue
+		}
+		if o.Type != f.Type {
+			changes = append(changes, SchemaChange{Field: f.Name, Kind: "type",
+				Detail: fmt.Sprintf("%s -> %s", typeName(o.Type), typeName(f.Type)), Breaking: true})
+		}
+		if !o.Nullable && f.Nullable {
+			changes = append(changes, SchemaChange{Field: f.Name, Kind: "nullable", Breaking: true})
+		}
+		if o.Required && !f.Required {
+			changes = append(changes, SchemaChange{Field: f.Name, Kind: "optional", Breaking: true})
+		}
+		if !o.Required && f.Required {
+			changes = append(changes, SchemaChange{Field: f.Name, Kind: "required"})
+		}
+	}
+	for _, f := range prev.Fields {
+		if !seen[f.Name] {
+			changes = append(changes, SchemaChange{Field: f.Name, Kind: "removed", Breaking: true})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+func typeName(t FieldType) string {
+	if t == TypeAny {
+		return "any"
+	}
+	return string(t)
+}
+
+// schemaReports 返回处理链中所有 SchemaInferrer 的推断结果。
+func (h *Handlers) schemaReports() []SchemaReport {
+	var reports []SchemaReport
+	h.eachHandler(func(v Handler) {
+		if si, ok := v.(*SchemaInferrer); ok {
+			reports = append(reports, si.Report())
+		}
+	})
+	return reports
+}
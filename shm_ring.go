@@ -0,0 +1,272 @@
+//go:build linux || darwin
+
+package handlers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// 共享内存环形缓冲区的头部布局，数据区从 shmHeader 开始。
+const (
+	shmMagic    = 0x68646c72 // "hdlr"
+	shmHead     = 0          // uint64，已写入的总字节数
+	shmTail     = 8          // uint64，已读取的总字节数
+	shmClosed   = 16         // uint32，为 1 时写入方已关闭
+	shmMagicOff = 20         // uint32
+	shmCap      = 24         // uint64，数据区大小
+	shmReader   = 32         // uint32，为 1 时读取方已关闭
+	shmHeader   = 64
+)
+
+// ShmPollInterval 共享内存环形缓冲区为空或已满时的轮询间隔。
+var ShmPollInterval = 50 * time.Microsecond
+
+// ShmWriteTimeout ShmRingSink 的 WriteTimeout 的默认值。
+var ShmWriteTimeout = 30 * time.Second
+
+// ErrShmRingFull 缓冲区一直是满的，超过 WriteTimeout 仍没有空间写入，通常是读取方已经退出。
+var ErrShmRingFull = errors.New("shm ring: buffer full, reader not consuming")
+
+// shmRing 映射到文件上的单生产者单消费者环形缓冲区，帧格式与 SocketSink 相同。
+type shmRing struct {
+	file *os.File
+	mem  []byte
+	data []byte
+	cap  uint64
+}
+
+func (r *shmRing) u64(off int) *uint64 { return (*uint64)(unsafe.Pointer(&r.mem[off])) }
+func (r *shmRing) u32(off int) *uint32 { return (*uint32)(unsafe.Pointer(&r.mem[off])) }
+
+func mapShmRing(file *os.File, size int) (*shmRing, error) {
+	mem, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return &shmRing{file: file, mem: mem, data: mem[shmHeader:], cap: uint64(size - shmHeader)}, nil
+}
+
+// copyIn 从总偏移 pos 处（取模后）写入 b。
+func (r *shmRing) copyIn(pos uint64, b []byte) {
+	i := pos % r.cap
+	n := copy(r.data[i:], b)
+	copy(r.data, b[n:])
+}
+
+// copyOut 从总偏移 pos 处（取模后）读出 len(b) 字节。
+func (r *shmRing) copyOut(pos uint64, b []byte) {
+	i := pos % r.cap
+	n := copy(b, r.data[i:])
+	copy(b[n:], r.data)
+}
+
+func (r *shmRing) unmap() error {
+	if r.mem == nil {
+		return nil
+	}
+	err := syscall.Munmap(r.mem)
+	r.mem, r.data = nil, nil
+	if cerr := r.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ShmRingSink 把数据写入共享内存环形缓冲区，由另一个进程中的 ShmRingSource 读取，
+// 适用于本机进程间吞吐量很高、不希望经过 socket 的场景。
+// 它是一个输出，通过 AddSink 添加；缓冲区已满时等待读取方，读取方关闭、Close 被调用
+// 或者等待超过 WriteTimeout 时 Write 返回错误。只支持一个写入方和一个读取方。
+type ShmRingSink struct {
+	// WriteTimeout 缓冲区已满时最多等待的时间，<= 0 时一直等待，默认为 ShmWriteTimeout。
+	WriteTimeout time.Duration
+
+	mu      sync.Mutex
+	ring    *shmRing
+	closing int32 // 为 1 时调用了 Close，等待中的 Write 立即返回
+}
+
+// NewShmRingSink 在 path（通常位于 /dev/shm）上创建数据区大小为 capacity 字节的环形缓冲区，已存在时清空。
+func NewShmRingSink(path string, capacity int) (*ShmRingSink, error) {
+	if capacity < 64 {
+		return nil, errors.New("shm ring: capacity too small")
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	size := shmHeader + capacity
+	if err = file.Truncate(int64(size)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	ring, err := mapShmRing(file, size)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	*ring.u64(shmCap) = uint64(capacity)
+	atomic.StoreUint32(ring.u32(shmMagicOff), shmMagic)
+	return &ShmRingSink{ring: ring, WriteTimeout: ShmWriteTimeout}, nil
+}
+
+// Write 实现 Sink 接口。
+func (sk *ShmRingSink) Write(out interface{}) error {
+	typ, payload, err := encodeFrame(out)
+	if err != nil {
+		return err
+	}
+	sk.mu.Lock()
+	defer sk.mu.Unlock()
+	r := sk.ring
+	if r == nil || r.mem == nil {
+		return errors.New("shm ring sink closed")
+	}
+	need := uint64(5 + len(payload))
+	if need > r.cap {
+		return fmt.Errorf("shm ring: frame of %d bytes exceeds capacity %d", need, r.cap)
+	}
+	head := atomic.LoadUint64(r.u64(shmHead))
+	var deadline time.Time
+	if sk.WriteTimeout > 0 {
+		deadline = time.Now().Add(sk.WriteTimeout)
+	}
+	for r.cap-(head-atomic.LoadUint64(r.u64(shmTail))) < need {
+		switch {
+		case atomic.LoadInt32(&sk.closing) == 1:
+			return errors.New("shm ring sink closed")
+		case atomic.LoadUint32(r.u32(shmReader)) == 1:
+			return errors.New("shm ring: reader closed")
+		case !deadline.IsZero() && time.Now().After(deadline):
+			return ErrShmRingFull
+		}
+		time.Sleep(ShmPollInterval)
+	}
+	var hdr [5]byte
+	binary.BigEndian.PutUint32(hdr[:4], uint32(len(payload)+1))
+	hdr[4] = typ
+	r.copyIn(head, hdr[:])
+	r.copyIn(head+5, payload)
+	atomic.StoreUint64(r.u64(shmHead), head+need)
+	return nil
+}
+
+// Flush 实现 Flusher 接口。写入的数据立即对读取方可见，没有需要写出的缓存。
+func (sk *ShmRingSink) Flush() error {
+	return nil
+}
+
+// Close 标记写入结束，读取方读完剩余数据后返回 io.EOF。正在等待空间的 Write 会返回错误。
+func (sk *ShmRingSink) Close() error {
+	atomic.StoreInt32(&sk.closing, 1)
+	sk.mu.Lock()
+	defer sk.mu.Unlock()
+	if sk.ring == nil {
+		return nil
+	}
+	atomic.StoreUint32(sk.ring.u32(shmClosed), 1)
+	err := sk.ring.unmap()
+	sk.ring = nil
+	return err
+}
+
+// ShmRingSource 从 ShmRingSink 创建的共享内存环形缓冲区读取数据，缓冲区为空时等待写入方。
+type ShmRingSource struct {
+	path string
+	ring *shmRing
+	n    int64 // 已经读取的帧数
+}
+
+// NewShmRingSrc 打开 ShmRingSink 在 path 上创建的环形缓冲区。
+func NewShmRingSrc(path string) (*ShmRingSource, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.Size() <= shmHeader {
+		file.Close()
+		return nil, errors.New("shm ring: not a ring buffer")
+	}
+	ring, err := mapShmRing(file, int(info.Size()))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if atomic.LoadUint32(ring.u32(shmMagicOff)) != shmMagic || *ring.u64(shmCap) != ring.cap {
+		ring.unmap()
+		return nil, errors.New("shm ring: not a ring buffer")
+	}
+	return &ShmRingSource{path: path, ring: ring}, nil
+}
+
+// Next 实现 Source 接口。
+func (ss *ShmRingSource) Next() (data interface{}, err error) {
+	r := ss.ring
+	if r == nil {
+		return nil, errors.New("shm ring source closed")
+	}
+	tail := atomic.LoadUint64(r.u64(shmTail))
+	for atomic.LoadUint64(r.u64(shmHead)) == tail {
+		// 先检查关闭标记再确认一次，避免漏掉关闭前写入的数据。
+		if atomic.LoadUint32(r.u32(shmClosed)) == 1 && atomic.LoadUint64(r.u64(shmHead)) == tail {
+			ss.Close()
+			return nil, io.EOF
+		}
+		time.Sleep(ShmPollInterval)
+	}
+	var hdr [5]byte
+	r.copyOut(tail, hdr[:])
+	size := uint64(binary.BigEndian.Uint32(hdr[:4]))
+	if size < 1 || size+4 > r.cap {
+		return nil, fmt.Errorf("shm ring: invalid frame size %d", size)
+	}
+	buf := make([]byte, size-1)
+	r.copyOut(tail+5, buf)
+	atomic.StoreUint64(r.u64(shmTail), tail+4+size)
+	ss.n++
+	return decodeFrame(hdr[4], buf)
+}
+
+// Name 实现 DescribedSource 接口。
+func (ss *ShmRingSource) Name() string { return ss.path }
+
+// Size 实现 DescribedSource 接口，大小未知时返回 -1。
+func (ss *ShmRingSource) Size() int64 { return -1 }
+
+// EstimatedItems 实现 DescribedSource 接口，数量未知时返回 -1。
+func (ss *ShmRingSource) EstimatedItems() int64 { return -1 }
+
+// Line 返回已经读取的帧数。
+func (ss *ShmRingSource) Line() int64 { return ss.n }
+
+// Health 实现 HealthChecker 接口。
+func (ss *ShmRingSource) Health() error {
+	if ss.ring == nil {
+		return errors.New("shm ring source closed")
+	}
+	return nil
+}
+
+// Close 取消映射，不删除文件。写入方随后不再等待缓冲区的空间。
+func (ss *ShmRingSource) Close() error {
+	if ss.ring == nil {
+		return nil
+	}
+	atomic.StoreUint32(ss.ring.u32(shmReader), 1)
+	err := ss.ring.unmap()
+	ss.ring = nil
+	return err
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SideEmitter 把数据 v 发送到名为 name 的旁路输出。
+type SideEmitter func(name string, v interface{}) error
+
+// SideOutputHandler 可选接口，处理器实现它以在主输出之外向命名的旁路输出发送数据，
+// 例如把格式错误的数据发送到 "malformed"。
+type SideOutputHandler interface {
+	Handler
+	// SetSideEmitter 在 Run 开始时调用，处理器保存 emit 并在 Handle 中使用。
+	SetSideEmitter(emit SideEmitter)
+}
+
+// Chain 依次执行的处理器，可以作为旁路输出的子处理链。
+type Chain []Handler
+
+// NewChain 新建处理链。
+func NewChain(handlers ...Handler) Chain {
+	return Chain(handlers)
+}
+
+// Handle 实现 Handler 接口。其中的处理器返回 Emit 时，每个元素分别交给后面的处理器，
+// 最终结果合并为一个 Emit 返回；返回 None 时 Handle 也返回 None。
+func (c Chain) Handle(in interface{}) (interface{}, error) {
+	return c.handleFrom(0, in)
+}
+
+func (c Chain) handleFrom(i int, in interface{}) (interface{}, error) {
+	for ; i < len(c); i++ {
+		out, err := callHandler(c[i], in)
+		if err != nil {
+			if errors.Is(err, ErrSkip) {
+				return None, nil
+			}
+			return nil, err
+		}
+		switch out.(type) {
+		case Emit, dropped:
+			return c.emitFrom(i+1, out)
+		}
+		in = out
+	}
+	return in, nil
+}
+
+// emitFrom 把处理器的输出 out 从第 i 个处理器开始处理，展开 Emit 并丢弃 None。
+func (c Chain) emitFrom(i int, out interface{}) (interface{}, error) {
+	switch v := out.(type) {
+	case Emit:
+		all := Emit{}
+		for _, item := range v {
+			o, err := c.emitFrom(i, item)
+			if err != nil {
+				return nil, err
+			}
+			switch ov := o.(type) {
+			case Emit:
+				all = append(all, ov...)
+			case dropped:
+			default:
+				all = append(all, o)
+			}
+		}
+		return all, nil
+	case dropped:
+		return None, nil
+	}
+	return c.handleFrom(i, out)
+}
+
+// SetSideOutput 把名为 name 的旁路输出连接到 handler，handler 为 nil 时删除该旁路输出。
+// handler 的返回值会被丢弃，需要多个处理步骤时可以使用 Chain。
+func (h *Handlers) SetSideOutput(name string, handler Handler) {
+	h.Lock()
+	defer h.Unlock()
+	if handler == nil {
+		delete(h.sideOutputs, name)
+		return
+	}
+	if h.sideOutputs == nil {
+		h.sideOutputs = make(map[string]Handler)
+	}
+	h.sideOutputs[name] = handler
+}
+
+// emitSide 实现 SideEmitter。
+func (h *Handlers) emitSide(name string, v interface{}) error {
+	h.RLock()
+	handler, ok := h.sideOutputs[name]
+	h.RUnlock()
+	if !ok {
+		return fmt.Errorf("side output %q not found", name)
+	}
+	_, err := handler.Handle(v)
+	return err
+}
+
+// bindSideOutputs 把旁路输出交给处理链中实现了 SideOutputHandler 的处理器。
+func (h *Handlers) bindSideOutputs() {
+	if h.handlers == nil {
+		return
+	}
+	h.handlers.RLock()
+	for e := h.handlers.Front(); e != nil; e = e.Next() {
+		if sh, ok := e.Value.(SideOutputHandler); ok {
+			sh.SetSideEmitter(h.emitSide)
+		}
+	}
+	h.handlers.RUnlock()
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// Sink 输出，处理链最后输出的数据依次写入所有输出。
+// 处理链中有异步处理器时 Write 可能被并发调用。
+type Sink interface {
+	Write(out interface{}) error
+	Close() error
+}
+
+// AddSink 添加输出，处理链最后一个处理器返回的数据（展开 Emit，丢弃 None 和空数据）写入所有输出。
+func (h *Handlers) AddSink(sinks ...Sink) {
+	h.Lock()
+	h.sinks = append(h.sinks, sinks...)
+	h.Unlock()
+}
+
+// hasSinks 是否添加了输出。
+func (h *Handlers) hasSinks() bool {
+	h.RLock()
+	defer h.RUnlock()
+	return len(h.sinks) > 0
+}
+
+// srcSink 可选接口，输出实现它以在开始处理一个源时得知当前的源。
+// resume 为 true 时源此前已经读取过数据（从进度恢复或者之前处理过一部分）。
+type srcSink interface {
+	beginSrc(src Source, resume bool) error
+}
+
+// beginSinks 开始处理一个源时通知实现了 srcSink 的输出。
+func (h *Handlers) beginSinks(src Source, resume bool) error {
+	h.RLock()
+	sinks := h.sinks
+	h.RUnlock()
+	for _, s := range sinks {
+		if ss, ok := s.(srcSink); ok {
+			if err := ss.beginSrc(src, resume); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeSinks 把处理链的输出写入所有输出。
+func (h *Handlers) writeSinks(out interface{}) error {
+	if isEmptyItem(out) {
+		return nil
+	}
+	h.RLock()
+	sinks := h.sinks
+	h.RUnlock()
+	for _, s := range sinks {
+		if err := s.Write(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CloseSinks 关闭所有输出，Run 不会关闭输出，所有 Run 结束后由调用方关闭。
+func (h *Handlers) CloseSinks() error {
+	h.RLock()
+	sinks := h.sinks
+	h.RUnlock()
+	errBuf := bytes.Buffer{}
+	for _, s := range sinks {
+		if err := s.Close(); err != nil {
+			if errBuf.Len() > 0 {
+				errBuf.WriteString("; ")
+			}
+			errBuf.WriteString(err.Error())
+		}
+	}
+	if errBuf.Len() > 0 {
+		return errors.New(errBuf.String())
+	}
+	return nil
+}
+
+// WriterSink 把数据逐行写入 io.Writer：string 和 []byte 原样写入，其他类型以 JSON 写入，
+// 不以换行结尾时补充换行。写入经过缓冲，Drain、Flush 和 Close 时写出。
+type WriterSink struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+	c  io.Closer // 不为 nil 时 Close 关闭它
+}
+
+// NewWriterSink 新建写入 w 的输出，Close 不关闭 w。
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: bufio.NewWriter(w)}
+}
+
+// NewStdoutSink 新建写入标准输出的输出。
+func NewStdoutSink() *WriterSink {
+	return NewWriterSink(os.Stdout)
+}
+
+// NewFileSink 新建写入文件的输出，文件已存在时清空原有内容。
+func NewFileSink(path string) (*WriterSink, error) {
+	return openWriterFile(path, os.O_TRUNC)
+}
+
+// NewAppendFileSink 新建写入文件的输出，文件已存在时追加写入。
+func NewAppendFileSink(path string) (*WriterSink, error) {
+	return openWriterFile(path, os.O_APPEND)
+}
+
+func openWriterFile(path string, mode int) (*WriterSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|mode, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &WriterSink{w: bufio.NewWriter(file), c: file}, nil
+}
+
+// Write 实现 Sink 接口。
+func (ws *WriterSink) Write(out interface{}) error {
+	var b []byte
+	switch v := out.(type) {
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.w == nil {
+		return errors.New("sink closed")
+	}
+	if _, err := ws.w.Write(b); err != nil {
+		return err
+	}
+	if len(b) == 0 || b[len(b)-1] != '\n' {
+		return ws.w.WriteByte('\n')
+	}
+	return nil
+}
+
+// Sync 实现 SyncSink 接口，写出缓冲的数据，写入文件时还会调用 fsync。
+func (ws *WriterSink) Sync() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.w == nil {
+		return errors.New("sink closed")
+	}
+	if err := ws.w.Flush(); err != nil {
+		return err
+	}
+	if f, ok := ws.c.(interface{ Sync() error }); ok {
+		return f.Sync()
+	}
+	return nil
+}
+
+// Flush 实现 Flusher 接口。
+func (ws *WriterSink) Flush() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.w == nil {
+		return nil
+	}
+	return ws.w.Flush()
+}
+
+// Close 实现 Sink 接口。
+func (ws *WriterSink) Close() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.w == nil {
+		return nil
+	}
+	err := ws.w.Flush()
+	if ws.c != nil {
+		if cerr := ws.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	ws.w = nil
+	return err
+}
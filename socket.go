@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sync"
+)
+
+// 帧格式：4 字节大端长度 + 1 字节类型 + 数据，长度包含类型字节。
+const (
+	frameBytes  byte = iota // []byte
+	frameString             // string
+	frameJSON               // 其他类型，以 JSON 编码，读出为 json.RawMessage
+)
+
+// MaxFrameSize 帧的最大长度，SocketSource 拒绝读取、SocketSink 拒绝写入更长的帧，不能超过 math.MaxUint32。
+var MaxFrameSize = 64 << 20
+
+// SocketSource 从 unix domain socket 读取 SocketSink 写入的数据，用于把处理链拆分到本机的两个进程中。
+// 它监听一个 socket 文件，第一次调用 Next 时等待对端连接，对端关闭连接后返回 io.EOF。
+type SocketSource struct {
+	path string
+	ln   net.Listener
+	conn net.Conn
+	r    *bufio.Reader
+	n    int64 // 已经读取的帧数
+}
+
+// NewSocketSrc 在 path 上监听，path 已存在时先删除。
+func NewSocketSrc(path string) (*SocketSource, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &SocketSource{path: path, ln: ln}, nil
+}
+
+// Next 实现 Source 接口。
+func (ss *SocketSource) Next() (data interface{}, err error) {
+	if ss.conn == nil {
+		if ss.ln == nil {
+			return nil, errors.New("socket source closed")
+		}
+		conn, err := ss.ln.Accept()
+		if err != nil {
+			return nil, err
+		}
+		ss.conn = conn
+		ss.r = bufio.NewReader(conn)
+	}
+	var head [5]byte
+	if _, err = io.ReadFull(ss.r, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("socket source: truncated frame header")
+		}
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint32(head[:4]))
+	if size < 1 || size > MaxFrameSize {
+		return nil, fmt.Errorf("socket source: invalid frame size %d", size)
+	}
+	buf := make([]byte, size-1)
+	if _, err = io.ReadFull(ss.r, buf); err != nil {
+		return nil, fmt.Errorf("socket source: truncated frame: %v", err)
+	}
+	ss.n++
+	return decodeFrame(head[4], buf)
+}
+
+// encodeFrame 返回数据的帧类型和内容。
+func encodeFrame(in interface{}) (typ byte, payload []byte, err error) {
+	switch v := in.(type) {
+	case []byte:
+		return frameBytes, v, nil
+	case string:
+		return frameString, []byte(v), nil
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		return 0, nil, fmt.Errorf("encode frame: %v", err)
+	}
+	return frameJSON, b, nil
+}
+
+// decodeFrame 按帧类型还原数据。
+func decodeFrame(typ byte, buf []byte) (interface{}, error) {
+	switch typ {
+	case frameBytes:
+		return buf, nil
+	case frameString:
+		return string(buf), nil
+	case frameJSON:
+		return json.RawMessage(buf), nil
+	}
+	return nil, fmt.Errorf("unknown frame type %d", typ)
+}
+
+// Name 实现 DescribedSource 接口。
+func (ss *SocketSource) Name() string { return ss.path }
+
+// Size 实现 DescribedSource 接口，大小未知时返回 -1。
+func (ss *SocketSource) Size() int64 { return -1 }
+
+// EstimatedItems 实现 DescribedSource 接口，数量未知时返回 -1。
+func (ss *SocketSource) EstimatedItems() int64 { return -1 }
+
+// Line 返回已经读取的帧数。
+func (ss *SocketSource) Line() int64 { return ss.n }
+
+// Health 实现 HealthChecker 接口。
+func (ss *SocketSource) Health() error {
+	if ss.ln == nil {
+		return errors.New("socket source closed")
+	}
+	return nil
+}
+
+// Close 关闭连接和监听，并删除 socket 文件。
+func (ss *SocketSource) Close() error {
+	var err error
+	if ss.conn != nil {
+		err = ss.conn.Close()
+		ss.conn = nil
+	}
+	if ss.ln != nil {
+		if lerr := ss.ln.Close(); err == nil {
+			err = lerr
+		}
+		ss.ln = nil
+	}
+	return err
+}
+
+// SocketSink 把数据写入 unix domain socket，由另一个进程中的 SocketSource 读取。
+// 它是一个输出，通过 AddSink 添加；写入经过缓冲，Flush 和 Close 时写出。
+// string 和 []byte 原样传输，其他类型以 JSON 编码传输。
+type SocketSink struct {
+	mu   sync.Mutex
+	conn net.Conn
+	w    *bufio.Writer
+}
+
+// NewSocketSink 连接 path 上的 SocketSource。
+func NewSocketSink(path string) (*SocketSink, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &SocketSink{conn: conn, w: bufio.NewWriter(conn)}, nil
+}
+
+// Write 实现 Sink 接口。
+func (sk *SocketSink) Write(out interface{}) error {
+	typ, payload, err := encodeFrame(out)
+	if err != nil {
+		return err
+	}
+	size := int64(len(payload)) + 1
+	if size > int64(MaxFrameSize) || size > math.MaxUint32 {
+		return fmt.Errorf("socket sink: frame size %d exceeds limit %d", size, MaxFrameSize)
+	}
+	var head [5]byte
+	binary.BigEndian.PutUint32(head[:4], uint32(size))
+	head[4] = typ
+
+	sk.mu.Lock()
+	defer sk.mu.Unlock()
+	if sk.conn == nil {
+		return errors.New("socket sink closed")
+	}
+	if _, err := sk.w.Write(head[:]); err != nil {
+		return err
+	}
+	_, err = sk.w.Write(payload)
+	return err
+}
+
+// Flush 实现 Flusher 接口。
+func (sk *SocketSink) Flush() error {
+	sk.mu.Lock()
+	defer sk.mu.Unlock()
+	if sk.conn == nil {
+		return nil
+	}
+	return sk.w.Flush()
+}
+
+// Close 写入缓存的数据后关闭连接，对端的 SocketSource 随后返回 io.EOF。
+func (sk *SocketSink) Close() error {
+	sk.mu.Lock()
+	defer sk.mu.Unlock()
+	if sk.conn == nil {
+		return nil
+	}
+	err := sk.w.Flush()
+	if cerr := sk.conn.Close(); err == nil {
+		err = cerr
+	}
+	sk.conn = nil
+	return err
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// LabeledSource 可选接口，数据源实现它以提供用于输出命名的标签。
+type LabeledSource interface {
+	Source
+	Labels() map[string]string
+}
+
+// SourceInfo 用于输出文件名模板的数据源信息。
+type SourceInfo struct {
+	Source     string            // 数据源的名称（DescribedSource），例如 logs/app-2024-01-02.log
+	SourceBase string            // 去掉目录和扩展名的名称，例如 app-2024-01-02
+	SourceExt  string            // 扩展名，例如 .log
+	SourceDir  string            // 目录，例如 logs
+	Date       time.Time         // 从名称中解析出的日期（yyyy-mm-dd、yyyymmdd 等），没有时为零值
+	Labels     map[string]string // LabeledSource 的标签
+}
+
+var sourceDateRe = regexp.MustCompile(`(\d{4})[-_.]?(\d{2})[-_.]?(\d{2})`)
+
+// NewSourceInfo 返回 src 的信息，src 没有实现 DescribedSource 时名称为其类型名。
+func NewSourceInfo(src Source) SourceInfo {
+	name := fmt.Sprintf("%T", src)
+	if ds, ok := src.(DescribedSource); ok {
+		name = ds.Name()
+	}
+	base := filepath.Base(name)
+	ext := filepath.Ext(base)
+	info := SourceInfo{
+		Source:     name,
+		SourceBase: strings.TrimSuffix(base, ext),
+		SourceExt:  ext,
+		SourceDir:  filepath.Dir(name),
+	}
+	for _, m := range sourceDateRe.FindAllStringSubmatch(base, -1) {
+		if t, err := time.Parse("20060102", m[1]+m[2]+m[3]); err == nil {
+			info.Date = t
+			break
+		}
+	}
+	if ls, ok := src.(LabeledSource); ok {
+		info.Labels = ls.Labels()
+	}
+	return info
+}
+
+// SourceFileSink 每个数据源的输出写入单独的文件，文件名由 text/template 模板根据 SourceInfo 生成，
+// 例如 "out/processed-{{.SourceBase}}.jsonl" 或 "out/{{.Date.Format \"2006/01/02\"}}/{{.SourceBase}}.jsonl"。
+// 每次开始处理一个源时切换到它的输出，所以要求同一时间只处理一个源（SetConcurrency(1)，为默认值）。
+// 一个源处理完或 Run 结束时关闭它的输出；文件在一次运行中第一次打开时清空原有内容，
+// 同一个文件名再次出现、源从进度恢复或者在 Drain、Stop 等之后继续处理时追加写入。
+type SourceFileSink struct {
+	tmpl *template.Template
+	open func(path string, appendTo bool) (Sink, error)
+
+	mu    sync.Mutex
+	src   Source // 当前输出对应的源
+	cur   Sink
+	path  string
+	paths []string
+	err   error // 打开输出失败的错误，在 Write 时返回
+}
+
+// NewSourceFileSink 新建按源命名的文件输出，并添加到 h 的输出中。
+// open 打开一个输出文件，appendTo 为 true 时应当追加写入，同一个文件名再次出现时会再次调用；
+// 为 nil 时使用 NewFileSink 或 NewAppendFileSink（会创建不存在的目录）。
+func NewSourceFileSink(h *Handlers, tmpl string, open func(path string, appendTo bool) (Sink, error)) (*SourceFileSink, error) {
+	t, err := template.New("sink").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	if open == nil {
+		open = openFileSink
+	}
+	ss := &SourceFileSink{tmpl: t, open: open}
+	h.OnEvent(ss.onEvent)
+	h.AddSink(ss)
+	return ss, nil
+}
+
+func openFileSink(path string, appendTo bool) (Sink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if appendTo {
+		return NewAppendFileSink(path)
+	}
+	return NewFileSink(path)
+}
+
+// opened 返回 path 在本次运行中是否已经打开过。调用方需持有 ss.mu。
+func (ss *SourceFileSink) opened(path string) bool {
+	for _, p := range ss.paths {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// OutputName 返回源 src 的输出文件名。
+func (ss *SourceFileSink) OutputName(src Source) (string, error) {
+	var b bytes.Buffer
+	if err := ss.tmpl.Execute(&b, NewSourceInfo(src)); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (ss *SourceFileSink) onEvent(ev Event) {
+	switch ev.Kind {
+	case EventRunStart:
+		ss.mu.Lock()
+		ss.paths = nil
+		ss.mu.Unlock()
+	case EventSourceExhausted, EventRunStop:
+		ss.mu.Lock()
+		ss.closeCur()
+		ss.mu.Unlock()
+	}
+}
+
+// beginSrc 实现 srcSink 接口，切换到 src 的输出。
+func (ss *SourceFileSink) beginSrc(src Source, resume bool) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.cur != nil && ss.src == src {
+		return nil
+	}
+	ss.closeCur()
+	path, err := ss.OutputName(src)
+	if err == nil {
+		ss.cur, err = ss.open(path, resume || ss.opened(path))
+	}
+	if err != nil {
+		return fmt.Errorf("source file sink: %v", err)
+	}
+	ss.src, ss.path = src, path
+	ss.paths = append(ss.paths, path)
+	return nil
+}
+
+// closeCur 关闭当前的输出。调用方需持有 ss.mu。
+func (ss *SourceFileSink) closeCur() {
+	if ss.cur == nil {
+		return
+	}
+	if err := ss.cur.Close(); err != nil && ss.err == nil {
+		ss.err = fmt.Errorf("source file sink: close %s: %v", ss.path, err)
+	}
+	ss.src, ss.cur, ss.path = nil, nil, ""
+}
+
+// Paths 返回本次运行中打开过的输出文件。
+func (ss *SourceFileSink) Paths() []string {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return append([]string(nil), ss.paths...)
+}
+
+// Write 实现 Sink 接口。
+func (ss *SourceFileSink) Write(out interface{}) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.err != nil {
+		return ss.err
+	}
+	if ss.cur == nil {
+		return errors.New("source file sink: no current source")
+	}
+	return ss.cur.Write(out)
+}
+
+// Close 实现 Sink 接口。
+func (ss *SourceFileSink) Close() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.closeCur()
+	err := ss.err
+	ss.err = nil
+	return err
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import "io"
+
+// SplittableSource 可以拆分成多个相互独立的分片的数据源。
+type SplittableSource interface {
+	Source
+	// Split 把尚未读取的数据拆分成最多 n 个分片，拆分后不应再使用原数据源。
+	// 无法拆分时返回只包含自身的切片。
+	Split(n int) []Source
+}
+
+// AddSplitSrc 添加待处理的数据源，src 实现了 SplittableSource 时拆分成最多 n 个分片后添加。
+func (h *Handlers) AddSplitSrc(src Source, n int) {
+	ss, ok := src.(SplittableSource)
+	if !ok {
+		h.AddSrc(src)
+		return
+	}
+	for _, part := range ss.Split(n) {
+		h.AddSrc(part)
+	}
+}
+
+// Split 实现 SplittableSource 接口，按字节范围把文件拆分成 n 个分片，分片边界对齐到行。
+func (fs *FileSource) Split(n int) []Source {
+	if n <= 1 || fs.file == nil {
+		return []Source{fs}
+	}
+	info, err := fs.file.Stat()
+	if err != nil {
+		return []Source{fs}
+	}
+	start, end := fs.offset, info.Size()
+	if fs.limit > 0 && fs.limit < end {
+		end = fs.limit
+	}
+	chunk := (end - start) / int64(n)
+	if chunk == 0 {
+		return []Source{fs}
+	}
+
+	parts := make([]Source, 0, n)
+	for i := 0; i < n; i++ {
+		partEnd := start + chunk
+		if i == n-1 {
+			partEnd = end
+		}
+		part, err := newFileRangeSrc(fs.path, start, partEnd, i > 0)
+		if err != nil {
+			for _, p := range parts {
+				p.(*FileSource).Close()
+			}
+			return []Source{fs}
+		}
+		parts = append(parts, part)
+		start = partEnd
+	}
+	fs.Close()
+	return parts
+}
+
+// newFileRangeSrc 新建只读取 [start, end) 范围内的行的文件源。
+// skipPartial 为 true 时跳过 start 处不完整的行，这一行属于上一个分片。
+func newFileRangeSrc(filePath string, start, end int64, skipPartial bool) (*FileSource, error) {
+	if !skipPartial {
+		fs, err := newFileSrcAt(filePath, start)
+		if err != nil {
+			return nil, err
+		}
+		fs.limit = end
+		return fs, nil
+	}
+	// 从 start-1 开始读到第一个换行符，如果 start-1 正好是换行符则不会跳过任何行。
+	fs, err := newFileSrcAt(filePath, start-1)
+	if err != nil {
+		return nil, err
+	}
+	skipped, err := fs.r.ReadString('\n')
+	fs.offset += int64(len(skipped))
+	fs.lineFrom, fs.lineBase = fs.offset, -1
+	if err != nil && err != io.EOF {
+		fs.Close()
+		return nil, err
+	}
+	fs.limit = end
+	return fs, nil
+}
+
+// Split 实现 SplittableSource 接口，把尚未读取的文件平均分成最多 n 组。
+func (mfs *MultiFileSrc) Split(n int) []Source {
+	rest := mfs.src[mfs.index:]
+	if n <= 1 || len(rest) <= 1 {
+		return []Source{mfs}
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	parts := make([]Source, 0, n)
+	for i := 0; i < n; i++ {
+		lo, hi := i*len(rest)/n, (i+1)*len(rest)/n
+		parts = append(parts, &MultiFileSrc{src: rest[lo:hi]})
+	}
+	mfs.index = len(mfs.src)
+	return parts
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// srcEntry 待处理和已处理队列中的元素，记录源的读取量。
+type srcEntry struct {
+	src   Source
+	items int64 // 读取的数据条数，对于文件源即行数
+	bytes int64 // 读取的字节数，只统计 string 和 []byte 类型的数据
+
+	opened   int32     // 为 1 时已经触发过 EventSourceOpened
+	restored bool      // 是否从保存的进度恢复
+	saved    time.Time // 上次保存进度的时间
+	fl       flight    // 这个源尚未处理完的数据
+}
+
+// count 记录读取了一条数据，返回数据的字节数。
+func (ent *srcEntry) count(d interface{}) (n int64) {
+	atomic.AddInt64(&ent.items, 1)
+	switch v := d.(type) {
+	case string:
+		n = int64(len(v))
+	case []byte:
+		n = int64(len(v))
+	}
+	atomic.AddInt64(&ent.bytes, n)
+	return n
+}
+
+// isEmptyItem 判断源在结束时（返回 err 的同时）返回的数据是否为空，空数据不计入读取量，也不交给处理链。
+func isEmptyItem(d interface{}) bool {
+	switch v := d.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []byte:
+		return len(v) == 0
+	}
+	return false
+}
+
+// SourceStat 单个源的读取量。
+type SourceStat struct {
+	Source Source
+	Name   string // 实现了 DescribedSource 时为源的名称
+	Items  int64  // 读取的数据条数，对于文件源即行数
+	Bytes  int64  // 读取的字节数，只统计 string 和 []byte 类型的数据
+	Done   bool   // 是否已经处理完毕
+}
+
+// SourceStats 返回所有源的读取量，依次为已处理的、正在处理的和待处理的源。
+// 正在处理的源的读取量随处理增长，每个源只出现一次。
+func (h *Handlers) SourceStats() []SourceStat {
+	h.activeMu.Lock()
+	defer h.activeMu.Unlock()
+	var stats []SourceStat
+	add := func(ent *srcEntry, done bool) {
+		stat := SourceStat{
+			Source: ent.src,
+			Items:  atomic.LoadInt64(&ent.items),
+			Bytes:  atomic.LoadInt64(&ent.bytes),
+			Done:   done,
+		}
+		if ds, ok := ent.src.(DescribedSource); ok {
+			stat.Name = ds.Name()
+		}
+		stats = append(stats, stat)
+	}
+	addList := func(l *safeList, done bool) {
+		if l == nil {
+			return
+		}
+		l.RLock()
+		for e := l.Front(); e != nil; e = e.Next() {
+			add(e.Value.(*srcEntry), done)
+		}
+		l.RUnlock()
+	}
+	addList(h.doneSrc, true)
+	for _, ent := range h.active {
+		add(ent, false)
+	}
+	addList(h.todoSrc, false)
+	return stats
+}
+
+// totals 返回所有源读取的数据条数和字节数之和。
+func (h *Handlers) totals() (items, bytes int64) {
+	for _, stat := range h.SourceStats() {
+		items += stat.Items
+		bytes += stat.Bytes
+	}
+	return items, bytes
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// FlushStats StaleFlushSink 的刷新统计，延迟为最早一条未刷新的数据写入到刷新完成的时间。
+type FlushStats struct {
+	Flushes     int64         // 刷新次数
+	Forced      int64         // 因为超过 maxAge 而强制刷新的次数
+	Errors      int64         // 刷新失败的次数
+	LastLatency time.Duration // 最近一次刷新的延迟
+	MaxLatency  time.Duration // 最大的刷新延迟
+}
+
+// StaleFlushSink 保证写入的数据最多缓存 maxAge 就被刷新，不必等缓冲区写满，适用于准实时的下游。
+// 被包装的输出需要实现 Flusher，否则只透传写入。后台刷新失败的错误在下一次 Write 时返回。
+type StaleFlushSink struct {
+	s      Sink
+	f      Flusher
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	oldest  time.Time // 最早一条未刷新的数据写入的时间，零值表示没有未刷新的数据
+	flushMu sync.Mutex
+	err     error
+	stats   FlushStats
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewStaleFlushSink 包装输出 s，maxAge 为数据在缓冲区中停留的最长时间。
+func NewStaleFlushSink(s Sink, maxAge time.Duration) *StaleFlushSink {
+	ss := &StaleFlushSink{s: s, maxAge: maxAge}
+	ss.f, _ = s.(Flusher)
+	if ss.f != nil && maxAge > 0 {
+		ss.stop = make(chan struct{})
+		ss.wg.Add(1)
+		go ss.loop()
+	}
+	return ss
+}
+
+func (ss *StaleFlushSink) loop() {
+	defer ss.wg.Done()
+	interval := ss.maxAge / 4
+	if interval <= 0 {
+		interval = ss.maxAge
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ss.stop:
+			return
+		case now := <-t.C:
+			ss.mu.Lock()
+			stale := !ss.oldest.IsZero() && now.Sub(ss.oldest) >= ss.maxAge-interval
+			ss.mu.Unlock()
+			if stale {
+				ss.flush(true)
+			}
+		}
+	}
+}
+
+// Write 实现 Sink 接口。
+func (ss *StaleFlushSink) Write(out interface{}) error {
+	ss.mu.Lock()
+	if err := ss.err; err != nil {
+		ss.err = nil
+		ss.mu.Unlock()
+		return err
+	}
+	if ss.oldest.IsZero() {
+		ss.oldest = time.Now()
+	}
+	ss.mu.Unlock()
+	return ss.s.Write(out)
+}
+
+// flush 刷新被包装的输出并记录延迟，forced 表示由后台定时触发。
+func (ss *StaleFlushSink) flush(forced bool) error {
+	if ss.f == nil {
+		return nil
+	}
+	ss.flushMu.Lock()
+	defer ss.flushMu.Unlock()
+	ss.mu.Lock()
+	oldest := ss.oldest
+	ss.oldest = time.Time{}
+	ss.mu.Unlock()
+	err := ss.f.Flush()
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if err != nil {
+		ss.stats.Errors++
+		if forced {
+			ss.err = err
+		}
+		if ss.oldest.IsZero() || oldest.Before(ss.oldest) {
+			ss.oldest = oldest // 下次重试
+		}
+		return err
+	}
+	ss.stats.Flushes++
+	if forced {
+		ss.stats.Forced++
+	}
+	if !oldest.IsZero() {
+		lat := time.Since(oldest)
+		ss.stats.LastLatency = lat
+		if lat > ss.stats.MaxLatency {
+			ss.stats.MaxLatency = lat
+		}
+	}
+	return nil
+}
+
+// Flush 实现 Flusher 接口。
+func (ss *StaleFlushSink) Flush() error {
+	return ss.flush(false)
+}
+
+// Stats 返回刷新统计。
+func (ss *StaleFlushSink) Stats() FlushStats {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.stats
+}
+
+// Close 实现 Sink 接口，停止后台刷新并关闭被包装的输出。
+func (ss *StaleFlushSink) Close() error {
+	if ss.stop != nil {
+		close(ss.stop)
+		ss.wg.Wait()
+		ss.stop = nil
+	}
+	return ss.s.Close()
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HandlerStat 单个处理器的计数。
+type HandlerStat struct {
+	Name    string
+	Handled int64 // 处理的数据条数（包括出错和丢弃的）
+	Errors  int64 // 返回错误的次数，不包括 ErrSkip
+	Skipped int64 // 返回 None 或 ErrSkip 丢弃的数据条数
+}
+
+// Stats 当前或最近一次 Run 的统计快照。
+type Stats struct {
+	State       int32
+	Start       time.Time
+	Elapsed     time.Duration // Run 结束后为 Run 的总耗时
+	ItemsRead   int64         // 所有源读取的数据条数
+	BytesRead   int64         // 所有源读取的字节数，只统计 string 和 []byte 类型的数据
+	Errors      int64         // 所有处理器返回错误的次数
+	Skipped     int64         // 所有处理器丢弃的数据条数
+	ItemsPerSec float64       // 平均每秒读取的数据条数
+	Handlers    []HandlerStat // 按处理链的顺序
+}
+
+type handlerCounter struct {
+	name                     string
+	handled, errors, skipped int64
+}
+
+// runStats 一次 Run 的计数，计数器都用原子操作，Stats 可以在运行中随时调用。
+type runStats struct {
+	mu         sync.Mutex
+	start, end time.Time
+	counters   atomic.Value // 类型为 map[*list.Element]*handlerCounter，Run 开始和处理链修改时整体替换
+	order      []*handlerCounter
+
+	items, bytes int64
+}
+
+func (rs *runStats) read(bytes int64) {
+	atomic.AddInt64(&rs.items, 1)
+	atomic.AddInt64(&rs.bytes, bytes)
+}
+
+func (rs *runStats) finish() {
+	rs.mu.Lock()
+	rs.end = time.Now()
+	rs.mu.Unlock()
+}
+
+// resetStats 在 Run 开始时清空计数，为处理链中的每个处理器创建计数器。
+func (h *Handlers) resetStats(start time.Time) {
+	h.chainMu.Lock()
+	defer h.chainMu.Unlock()
+	counters := make(map[*list.Element]*handlerCounter)
+	var order []*handlerCounter
+	if h.handlers != nil {
+		h.handlers.RLock()
+		for e := h.handlers.Front(); e != nil; e = e.Next() {
+			c := &handlerCounter{name: handlerName(e.Value.(Handler))}
+			counters[e] = c
+			order = append(order, c)
+		}
+		h.handlers.RUnlock()
+	}
+	rs := &h.stats
+	rs.mu.Lock()
+	rs.start, rs.end = start, time.Time{}
+	rs.counters.Store(counters)
+	rs.order = order
+	atomic.StoreInt64(&rs.items, 0)
+	atomic.StoreInt64(&rs.bytes, 0)
+	rs.mu.Unlock()
+}
+
+// remapStats 处理链被复制为 l 后，让副本中的处理器沿用原来的计数器，新加入的处理器创建计数器。
+// 原来的元素仍然保留，旧的处理链上正在处理的数据也能计数。调用方需持有 h.chainMu。
+func (h *Handlers) remapStats(l *list.List, elems map[*list.Element]*list.Element) {
+	rs := &h.stats
+	old, _ := rs.counters.Load().(map[*list.Element]*handlerCounter)
+	if old == nil {
+		return // 还没有 Run 过，Run 开始时会创建计数器
+	}
+	counters := make(map[*list.Element]*handlerCounter, len(old)+l.Len())
+	for e, c := range old {
+		counters[e] = c
+	}
+	for oe, ne := range elems {
+		if c, ok := old[oe]; ok {
+			counters[ne] = c
+		}
+	}
+	var order []*handlerCounter
+	for e := l.Front(); e != nil; e = e.Next() {
+		c, ok := counters[e]
+		if !ok {
+			c = &handlerCounter{name: handlerName(e.Value.(Handler))}
+			counters[e] = c
+		}
+		order = append(order, c)
+	}
+	rs.mu.Lock()
+	rs.counters.Store(counters)
+	rs.order = order
+	rs.mu.Unlock()
+}
+
+// observe 记录处理器 e 处理数据 in 的结果，并触发对应的事件。
+func (h *Handlers) observe(e *list.Element, in, out interface{}, err error) {
+	counters, _ := h.stats.counters.Load().(map[*list.Element]*handlerCounter)
+	c, ok := counters[e]
+	if !ok {
+		return
+	}
+	atomic.AddInt64(&c.handled, 1)
+	kind := EventKind(-1)
+	switch {
+	case errors.Is(err, ErrSkip):
+		atomic.AddInt64(&c.skipped, 1)
+		kind = EventItemDropped
+	case err != nil:
+		atomic.AddInt64(&c.errors, 1)
+		kind = EventHandlerError
+	case out == None:
+		atomic.AddInt64(&c.skipped, 1)
+		kind = EventItemDropped
+	}
+	if kind >= 0 && h.hasListeners() {
+		h.emitEvent(Event{Kind: kind, Time: time.Now(), Handler: c.name, Item: in, Err: err})
+	}
+}
+
+// Stats 返回当前或最近一次 Run 的统计快照，运行中也可以调用。RunChainOn 不计入统计。
+func (h *Handlers) Stats() Stats {
+	rs := &h.stats
+	rs.mu.Lock()
+	start, end, order := rs.start, rs.end, rs.order
+	rs.mu.Unlock()
+	s := Stats{
+		State:     h.State(),
+		Start:     start,
+		ItemsRead: atomic.LoadInt64(&rs.items),
+		BytesRead: atomic.LoadInt64(&rs.bytes),
+	}
+	if !start.IsZero() {
+		if end.IsZero() {
+			end = time.Now()
+		}
+		s.Elapsed = end.Sub(start)
+	}
+	if s.Elapsed > 0 {
+		s.ItemsPerSec = float64(s.ItemsRead) / s.Elapsed.Seconds()
+	}
+	for _, c := range order {
+		hs := HandlerStat{
+			Name:    c.name,
+			Handled: atomic.LoadInt64(&c.handled),
+			Errors:  atomic.LoadInt64(&c.errors),
+			Skipped: atomic.LoadInt64(&c.skipped),
+		}
+		s.Errors += hs.Errors
+		s.Skipped += hs.Skipped
+		s.Handlers = append(s.Handlers, hs)
+	}
+	return s
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IDProvider 生成代理键。实现必须是并发安全的。
+type IDProvider interface {
+	NextID() (interface{}, error)
+}
+
+// IDProviderFunc 函数形式的 IDProvider。
+type IDProviderFunc func() (interface{}, error)
+
+// NextID 实现 IDProvider 接口。
+func (f IDProviderFunc) NextID() (interface{}, error) { return f() }
+
+// UUIDv7 生成 RFC 9562 的 UUIDv7 字符串，按时间递增，同一毫秒内也保持递增。
+type UUIDv7 struct {
+	mu     sync.Mutex
+	lastMs int64
+	seq    uint16 // 12 位，同一毫秒内递增
+}
+
+// NewUUIDv7 新建 UUIDv7 生成器。
+func NewUUIDv7() *UUIDv7 { return &UUIDv7{} }
+
+// NextID 实现 IDProvider 接口。
+func (u *UUIDv7) NextID() (interface{}, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	u.mu.Lock()
+	ms := time.Now().UnixMilli()
+	if ms <= u.lastMs {
+		u.seq++
+		if u.seq > 0xfff { // 同一毫秒内用完了，借用下一毫秒
+			u.lastMs++
+			u.seq = 0
+		}
+		ms = u.lastMs
+	} else {
+		u.lastMs = ms
+		u.seq = uint16(b[6]&0x07)<<8 | uint16(b[7]) // 随机起点，留出递增的空间
+	}
+	seq := u.seq
+	u.mu.Unlock()
+
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+	b[6] = 0x70 | byte(seq>>8)
+	b[7] = byte(seq)
+	b[8] = b[8]&0x3f | 0x80
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:]), nil
+}
+
+// SnowflakeEpoch Snowflake ID 中时间戳的起点。
+var SnowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflake 生成 snowflake 风格的 int64 ID：41 位毫秒时间戳、10 位节点号、12 位序号。
+// 不同进程使用不同的节点号即可保证不重复。
+type Snowflake struct {
+	mu     sync.Mutex
+	node   int64
+	lastMs int64
+	seq    int64
+}
+
+// NewSnowflake 新建 Snowflake 生成器，node 的范围为 [0, 1023]。
+func NewSnowflake(node int64) (*Snowflake, error) {
+	if node < 0 || node > 1023 {
+		return nil, fmt.Errorf("snowflake: node %d out of range [0, 1023]", node)
+	}
+	return &Snowflake{node: node}, nil
+}
+
+// NextID 实现 IDProvider 接口，同一毫秒内的序号用完时等待下一毫秒。
+func (sf *Snowflake) NextID() (interface{}, error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	ms := time.Since(SnowflakeEpoch).Milliseconds()
+	if ms < sf.lastMs { // 时钟回拨时沿用上次的时间戳
+		ms = sf.lastMs
+	}
+	if ms == sf.lastMs {
+		sf.seq = (sf.seq + 1) & 0xfff
+		if sf.seq == 0 {
+			for ms <= sf.lastMs {
+				time.Sleep(time.Millisecond / 10)
+				ms = time.Since(SnowflakeEpoch).Milliseconds()
+			}
+		}
+	} else {
+		sf.seq = 0
+	}
+	sf.lastMs = ms
+	return ms<<22 | sf.node<<12 | sf.seq, nil
+}
+
+// Sequence 持久化的递增序列，生成 int64 ID。每次从文件中预留 Step 个 ID，
+// 重启后从预留的上限继续，因此 ID 不重复但可能不连续。
+type Sequence struct {
+	path string
+	// Step 每次预留的 ID 个数，默认为 1000。
+	Step int64
+
+	mu   sync.Mutex
+	next int64
+	max  int64 // 已预留的上限（不含）
+}
+
+// NewSequence 打开 path 中保存的序列，文件不存在时从 start 开始。
+func NewSequence(path string, start int64) (*Sequence, error) {
+	s := &Sequence{path: path, Step: 1000, next: start, max: start}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("sequence: bad state file %s: %v", path, err)
+	}
+	s.next, s.max = n, n
+	return s, nil
+}
+
+// NextID 实现 IDProvider 接口。
+func (s *Sequence) NextID() (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next >= s.max {
+		step := s.Step
+		if step <= 0 {
+			step = 1
+		}
+		if err := s.save(s.next + step); err != nil {
+			return nil, err
+		}
+		s.max = s.next + step
+	}
+	id := s.next
+	s.next++
+	return id, nil
+}
+
+// save 把预留的上限写入文件。调用方需持有 s.mu。
+func (s *Sequence) save(max int64) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.WriteString(strconv.FormatInt(max, 10)); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// KeyAssigner 给数据的 Field 字段分配代理键，数据为 map[string]interface{}，不会修改原数据。
+// 设置了 NaturalKey 和 Store 时，相同自然键的数据总是分配到相同的代理键。
+type KeyAssigner struct {
+	field    string
+	provider IDProvider
+
+	// NaturalKey 返回数据的自然键。
+	NaturalKey func(in interface{}) string
+	// Store 保存自然键到代理键的映射。
+	Store Store
+	// Overwrite 为 false 时已有代理键的数据保持不变。
+	Overwrite bool
+}
+
+// NewKeyAssigner 新建代理键处理器，field 为按 . 分隔的字段路径。
+func NewKeyAssigner(field string, provider IDProvider) *KeyAssigner {
+	return &KeyAssigner{field: field, provider: provider}
+}
+
+// StateStore 实现 StatefulHandler 接口。
+func (ka *KeyAssigner) StateStore() Store {
+	return ka.Store
+}
+
+// SetStateStore 实现 StatefulHandler 接口。
+func (ka *KeyAssigner) SetStateStore(store Store) {
+	ka.Store = store
+}
+
+// Handle 实现 Handler 接口。
+func (ka *KeyAssigner) Handle(in interface{}) (interface{}, error) {
+	m, ok := in.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("key assigner: expects map[string]interface{}, got %T", in)
+	}
+	if !ka.Overwrite {
+		if v, ok := fieldValue(m, ka.field); ok && v != nil {
+			return in, nil
+		}
+	}
+	id, err := ka.nextID(in)
+	if err != nil {
+		return nil, err
+	}
+	return setField(m, strings.Split(ka.field, "."), id), nil
+}
+
+func (ka *KeyAssigner) nextID(in interface{}) (interface{}, error) {
+	if ka.NaturalKey == nil || ka.Store == nil {
+		return ka.provider.NextID()
+	}
+	var err error
+	id := ka.Store.Update(ka.NaturalKey(in), 0, func(old interface{}, ok bool) interface{} {
+		if ok {
+			return old
+		}
+		var id interface{}
+		id, err = ka.provider.NextID()
+		return id
+	})
+	if err != nil {
+		return nil, err
+	}
+	return id, nil
+}
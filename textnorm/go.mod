@@ -0,0 +1,10 @@
+module github.com/qn-zyc/handlers/textnorm
+
+go 1.26.0
+
+require (
+	github.com/qn-zyc/handlers v0.0.0-00010101000000-000000000000
+	golang.org/x/text v0.42.0
+)
+
+replace github.com/qn-zyc/handlers => ../
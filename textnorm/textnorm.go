@@ -0,0 +1,32 @@
+// Package textnorm 基于 golang.org/x/text 的 Unicode 规范化步骤，可以和 handlers.NormalizeString 组合使用。
+//
+// textnorm 是单独的模块（见 textnorm/go.mod），handlers 本身不依赖 golang.org/x/text。
+package textnorm
+
+import (
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/qn-zyc/handlers"
+)
+
+// 规范化形式
+var (
+	NFC  handlers.StringOp = norm.NFC.String
+	NFD  handlers.StringOp = norm.NFD.String
+	NFKC handlers.StringOp = norm.NFKC.String
+	NFKD handlers.StringOp = norm.NFKD.String
+)
+
+// StripAccents 分解后去掉所有组合符号再重新组合，适用于所有文字，例如 "Ångström" 得到 "Angstrom"。
+func StripAccents(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	out, _, err := transform.String(t, s)
+	if err != nil {
+		return s
+	}
+	return out
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrHandlerTimeout WithTimeout 包装的处理器超时时返回的错误（被包装）。
+var ErrHandlerTimeout = errors.New("handlers: handler timeout")
+
+type timeoutStage struct {
+	stage
+	d time.Duration
+}
+
+// WithTimeout 包装处理器，一条数据处理超过 d 时返回 ErrHandlerTimeout，而不是一直阻塞处理链。
+// 超时的调用仍在后台运行直到返回，其结果被丢弃，所以被包装的处理器需要能够并发调用。
+// 可以和 WithRetry 组合使用。
+func WithTimeout(h Handler, d time.Duration) Handler {
+	return &timeoutStage{stage: stage{h: h}, d: d}
+}
+
+type handleResult struct {
+	out interface{}
+	err error
+}
+
+// Handle 实现 Handler 接口。
+func (s *timeoutStage) Handle(in interface{}) (interface{}, error) {
+	if s.d <= 0 {
+		return callHandler(s.h, in)
+	}
+	done := make(chan handleResult, 1)
+	go func() {
+		out, err := callHandler(s.h, in)
+		done <- handleResult{out, err}
+	}()
+	t := time.NewTimer(s.d)
+	defer t.Stop()
+	select {
+	case r := <-done:
+		return r.out, r.err
+	case <-t.C:
+		return nil, fmt.Errorf("handler %s: %w after %s", handlerName(s.h), ErrHandlerTimeout, s.d)
+	}
+}
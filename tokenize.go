@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// EnglishStopwords 常见的英文停用词。
+var EnglishStopwords = stopwordSet(`a an and are as at be but by for from has have he her his i if in into is it its
+me my no not of on or our she so that the their them then there these they this to was we were what when where which
+who will with you your`)
+
+func stopwordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// Tokenize 按非字母数字的字符切分文本并转换为小写。
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Stem 简单的英文词干提取，只去掉常见的后缀，例如 "running" 得到 "runn"、"cities" 得到 "city"。
+// 结果只用于统计和检索时的归并，不保证是实际的单词。
+func Stem(w string) string {
+	switch {
+	case len(w) > 4 && strings.HasSuffix(w, "sses"):
+		return w[:len(w)-2]
+	case len(w) > 4 && strings.HasSuffix(w, "ies"):
+		return w[:len(w)-3] + "y"
+	case len(w) > 5 && strings.HasSuffix(w, "ing"):
+		return w[:len(w)-3]
+	case len(w) > 4 && strings.HasSuffix(w, "ed"):
+		return w[:len(w)-2]
+	case len(w) > 4 && strings.HasSuffix(w, "ly"):
+		return w[:len(w)-2]
+	case len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && !strings.HasSuffix(w, "us"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// NGrams 返回 tokens 中相邻的 n 个词用空格连接后的结果，n <= 1 时返回 tokens 本身。
+func NGrams(tokens []string, n int) []string {
+	if n <= 1 {
+		return tokens
+	}
+	var out []string
+	for i := 0; i+n <= len(tokens); i++ {
+		out = append(out, strings.Join(tokens[i:i+n], " "))
+	}
+	return out
+}
+
+// Tokenizer 把文本切分为词或 n-gram。输入为 string 时直接处理，
+// 否则处理 map[string]interface{} 中名为 Field 的字段（按 . 分隔的路径）。
+type Tokenizer struct {
+	field string
+
+	// Stopwords 不为 nil 时去掉其中的词，例如 EnglishStopwords。
+	Stopwords map[string]bool
+	// Stem 为 true 时对每个词做词干提取，在去掉停用词之后进行。
+	Stem bool
+	// N 大于 1 时输出相邻 N 个词组成的 n-gram。
+	N int
+	// MinLen 长度小于它的词被丢弃。
+	MinLen int
+	// Output 结果写入的字段，默认为 "tokens"；输入为 string 时忽略，直接返回 []string。
+	Output string
+	// Split 为 true 时每个词（或 n-gram）作为一条 string 数据交给后面的处理器，便于统计词频。
+	Split bool
+}
+
+// NewTokenizer 新建分词处理器，field 为空时输入应为 string。
+func NewTokenizer(field string) *Tokenizer {
+	return &Tokenizer{field: field, Output: "tokens"}
+}
+
+// Tokens 按配置处理文本。
+func (t *Tokenizer) Tokens(s string) []string {
+	words := Tokenize(s)
+	out := words[:0]
+	for _, w := range words {
+		if len([]rune(w)) < t.MinLen || t.Stopwords[w] {
+			continue
+		}
+		if t.Stem {
+			w = Stem(w)
+		}
+		out = append(out, w)
+	}
+	return NGrams(out, t.N)
+}
+
+// Handle 实现 Handler 接口。
+func (t *Tokenizer) Handle(in interface{}) (interface{}, error) {
+	var text string
+	m, isMap := in.(map[string]interface{})
+	switch {
+	case t.field == "":
+		s, ok := in.(string)
+		if !ok {
+			return nil, fmt.Errorf("tokenizer: expects string, got %T", in)
+		}
+		text = s
+	case isMap:
+		v, _ := fieldValue(m, t.field)
+		s, ok := v.(string)
+		if !ok && v != nil {
+			return nil, fmt.Errorf("tokenizer: field %q is %T, want string", t.field, v)
+		}
+		text = s
+	default:
+		return nil, fmt.Errorf("tokenizer: expects map[string]interface{}, got %T", in)
+	}
+	tokens := t.Tokens(text)
+	if t.Split {
+		out := make(Emit, len(tokens))
+		for i, tok := range tokens {
+			out[i] = tok
+		}
+		return out, nil
+	}
+	if t.field == "" {
+		return tokens, nil
+	}
+	output := t.Output
+	if output == "" {
+		output = "tokens"
+	}
+	return setField(m, strings.Split(output, "."), tokens), nil
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TxSink 支持两阶段提交的输出。Run 成功结束时先对所有 TxSink 调用 Prepare，
+// 全部成功后再依次 Commit；任何一个 Prepare 失败或 Run 出错（包括 Stop）时对所有 TxSink 调用 Rollback。
+// 这样多个输出要么都发布本次 Run 的数据，要么都不发布。Commit 阶段的失败无法回滚已经提交的输出，Run 返回该错误。
+type TxSink interface {
+	Sink
+	// Prepare 准备提交，返回 nil 表示 Commit 一定能够成功（尽力而为）。
+	Prepare() error
+	// Commit 发布本次 Run 写入的数据。
+	Commit() error
+	// Rollback 丢弃本次 Run 写入的数据。
+	Rollback() error
+}
+
+// txSinks 返回所有实现了 TxSink 的输出。
+func (h *Handlers) txSinks() []TxSink {
+	h.RLock()
+	defer h.RUnlock()
+	var txs []TxSink
+	for _, s := range h.sinks {
+		if tx, ok := s.(TxSink); ok {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
+
+// finishTx Run 结束时按 runErr 提交或回滚所有 TxSink，返回 Run 最终的错误。
+func (h *Handlers) finishTx(runErr error) error {
+	txs := h.txSinks()
+	if len(txs) == 0 {
+		return runErr
+	}
+	if runErr == nil {
+		for _, tx := range txs {
+			if err := tx.Prepare(); err != nil {
+				runErr = fmt.Errorf("tx: prepare: %v", err)
+				break
+			}
+		}
+	}
+	errBuf := bytes.Buffer{}
+	for _, tx := range txs {
+		var err error
+		if runErr == nil {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			if errBuf.Len() > 0 {
+				errBuf.WriteString("; ")
+			}
+			errBuf.WriteString(err.Error())
+		}
+	}
+	if runErr != nil {
+		return runErr // 回滚的错误不覆盖 Run 的错误
+	}
+	if errBuf.Len() > 0 {
+		return errors.New("tx: commit: " + errBuf.String())
+	}
+	return nil
+}
+
+// StagedSink 把写入的数据缓存在内存中，Commit 时才写入被包装的输出，Rollback 时丢弃。
+// 适用于数据量不大、目标本身不支持事务的输出。
+type StagedSink struct {
+	s      Sink
+	mu     sync.Mutex
+	staged []interface{}
+}
+
+// NewStagedSink 包装输出 s。
+func NewStagedSink(s Sink) *StagedSink {
+	return &StagedSink{s: s}
+}
+
+// Write 实现 Sink 接口。
+func (ss *StagedSink) Write(out interface{}) error {
+	ss.mu.Lock()
+	ss.staged = append(ss.staged, out)
+	ss.mu.Unlock()
+	return nil
+}
+
+// Prepare 实现 TxSink 接口，被包装的输出实现了 HealthChecker 时检查其状态。
+func (ss *StagedSink) Prepare() error {
+	if hc, ok := ss.s.(HealthChecker); ok {
+		return hc.Health()
+	}
+	return nil
+}
+
+// Commit 实现 TxSink 接口，写入所有缓存的数据并刷新被包装的输出。
+func (ss *StagedSink) Commit() error {
+	ss.mu.Lock()
+	staged := ss.staged
+	ss.staged = nil
+	ss.mu.Unlock()
+	for _, out := range staged {
+		if err := ss.s.Write(out); err != nil {
+			return err
+		}
+	}
+	if f, ok := ss.s.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Rollback 实现 TxSink 接口。
+func (ss *StagedSink) Rollback() error {
+	ss.mu.Lock()
+	ss.staged = nil
+	ss.mu.Unlock()
+	return nil
+}
+
+// Close 实现 Sink 接口，丢弃未提交的数据并关闭被包装的输出。
+func (ss *StagedSink) Close() error {
+	ss.Rollback()
+	return ss.s.Close()
+}
+
+// TxFileSink 事务性的文件输出：每次 Run 的数据先写入同目录的临时文件，Prepare 时 fsync，
+// Commit 时把临时文件重命名为目标文件（覆盖），Rollback 时删除临时文件。格式同 WriterSink。
+type TxFileSink struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+	ws   *WriterSink
+}
+
+// NewTxFileSink 新建事务性的文件输出。
+func NewTxFileSink(path string) *TxFileSink {
+	return &TxFileSink{path: path}
+}
+
+// Write 实现 Sink 接口。
+func (ts *TxFileSink) Write(out interface{}) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if err := ts.ensure(); err != nil {
+		return err
+	}
+	return ts.ws.Write(out)
+}
+
+// ensure 需要时创建临时文件。调用方需持有 ts.mu。
+func (ts *TxFileSink) ensure() error {
+	if ts.ws != nil {
+		return nil
+	}
+	f, err := os.CreateTemp(filepath.Dir(ts.path), filepath.Base(ts.path)+".tx*")
+	if err != nil {
+		return err
+	}
+	ts.file = f
+	ts.ws = &WriterSink{w: bufio.NewWriter(f)}
+	return nil
+}
+
+// Prepare 实现 TxSink 接口。
+func (ts *TxFileSink) Prepare() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.ws == nil {
+		return nil
+	}
+	if err := ts.ws.Flush(); err != nil {
+		return err
+	}
+	return ts.file.Sync()
+}
+
+// Commit 实现 TxSink 接口，本次 Run 没有写入数据时不修改目标文件。
+func (ts *TxFileSink) Commit() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.ws == nil {
+		return nil
+	}
+	err := ts.ws.Flush()
+	if cerr := ts.file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(ts.file.Name(), ts.path)
+	}
+	if err != nil {
+		os.Remove(ts.file.Name())
+	}
+	ts.file, ts.ws = nil, nil
+	return err
+}
+
+// Rollback 实现 TxSink 接口。
+func (ts *TxFileSink) Rollback() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.discard()
+}
+
+// discard 删除临时文件。调用方需持有 ts.mu。
+func (ts *TxFileSink) discard() error {
+	if ts.file == nil {
+		return nil
+	}
+	ts.file.Close()
+	err := os.Remove(ts.file.Name())
+	ts.file, ts.ws = nil, nil
+	return err
+}
+
+// Close 实现 Sink 接口，丢弃未提交的数据。
+func (ts *TxFileSink) Close() error {
+	return ts.Rollback()
+}
@@ -0,0 +1,184 @@
+// Package typed 基于类型参数的处理链 API，在编译期检查相邻处理器的类型，
+// 底层仍然使用 handlers.Handlers 执行，可以和动态 API 混合使用。
+package typed
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/qn-zyc/handlers"
+)
+
+// Source 类型化的数据源，结束时返回 io.EOF。
+type Source[T any] interface {
+	Next() (T, error)
+}
+
+// Handler 类型化的处理器。
+type Handler[In, Out any] interface {
+	Handle(in In) (Out, error)
+}
+
+// HandlerFunc 函数式 Handler。
+type HandlerFunc[In, Out any] func(in In) (Out, error)
+
+// Handle 实现 Handler 接口。
+func (f HandlerFunc[In, Out]) Handle(in In) (Out, error) { return f(in) }
+
+// Sink 类型化的输出。
+type Sink[T any] interface {
+	Write(out T) error
+	Close() error
+}
+
+// SliceSource 依次返回切片中的元素。
+type SliceSource[T any] struct {
+	items []T
+	i     int
+}
+
+// FromSlice 新建切片数据源。
+func FromSlice[T any](items ...T) *SliceSource[T] {
+	return &SliceSource[T]{items: items}
+}
+
+// Next 实现 Source 接口。
+func (s *SliceSource[T]) Next() (T, error) {
+	var zero T
+	if s.i >= len(s.items) {
+		return zero, io.EOF
+	}
+	s.i++
+	return s.items[s.i-1], nil
+}
+
+// SourceOf 把类型化的数据源转换为 handlers.Source，出错时不附带数据。
+func SourceOf[T any](src Source[T]) handlers.Source {
+	return sourceAdapter[T]{src}
+}
+
+type sourceAdapter[T any] struct{ src Source[T] }
+
+func (a sourceAdapter[T]) Next() (interface{}, error) {
+	v, err := a.src.Next()
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// HandlerOf 把类型化的处理器转换为 handlers.Handler。
+// 数据源结束时附带的 nil 数据直接丢弃；其他类型不符的数据返回错误。
+func HandlerOf[In, Out any](h Handler[In, Out]) handlers.Handler {
+	return handlers.HandlerFunc(func(in interface{}) (interface{}, error) {
+		if in == nil {
+			return handlers.None, nil
+		}
+		v, ok := in.(In)
+		if !ok {
+			var want In
+			return nil, fmt.Errorf("typed: handler %T expects %T, got %T", h, want, in)
+		}
+		return h.Handle(v)
+	})
+}
+
+// SinkOf 把类型化的输出转换为 handlers.Sink。
+func SinkOf[T any](sink Sink[T]) handlers.Sink {
+	return sinkAdapter[T]{sink}
+}
+
+type sinkAdapter[T any] struct{ sink Sink[T] }
+
+func (a sinkAdapter[T]) Write(out interface{}) error {
+	v, ok := out.(T)
+	if !ok {
+		var want T
+		return fmt.Errorf("typed: sink %T expects %T, got %T", a.sink, want, out)
+	}
+	return a.sink.Write(v)
+}
+
+func (a sinkAdapter[T]) Close() error { return a.sink.Close() }
+
+// Flush 实现 handlers.Flusher 接口。
+func (a sinkAdapter[T]) Flush() error {
+	if f, ok := a.sink.(handlers.Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Pipeline 类型化的处理链，T 为当前最后一个处理器的输出类型。
+// Go 的方法不能有类型参数，改变类型的步骤使用函数 Then 和 Map。
+type Pipeline[T any] struct {
+	h *handlers.Handlers
+}
+
+// From 以 srcs 为数据源新建处理链。
+func From[T any](srcs ...Source[T]) *Pipeline[T] {
+	h := &handlers.Handlers{}
+	for _, src := range srcs {
+		h.AddSrc(SourceOf(src))
+	}
+	return &Pipeline[T]{h: h}
+}
+
+// Then 在处理链末尾添加处理器。
+func Then[In, Out any](p *Pipeline[In], h Handler[In, Out]) *Pipeline[Out] {
+	p.h.AddHandler(HandlerOf(h))
+	return &Pipeline[Out]{h: p.h}
+}
+
+// Map 在处理链末尾添加处理函数。
+func Map[In, Out any](p *Pipeline[In], fn func(In) (Out, error)) *Pipeline[Out] {
+	return Then[In, Out](p, HandlerFunc[In, Out](fn))
+}
+
+// AddSrc 添加数据源。
+func (p *Pipeline[T]) AddSrc(src Source[T]) *Pipeline[T] {
+	p.h.AddSrc(SourceOf(src))
+	return p
+}
+
+// Filter 丢弃 keep 返回 false 的数据。
+func (p *Pipeline[T]) Filter(keep func(T) bool) *Pipeline[T] {
+	p.h.AddHandler(handlers.HandlerFunc(func(in interface{}) (interface{}, error) {
+		if v, ok := in.(T); ok && keep(v) {
+			return in, nil
+		}
+		return handlers.None, nil
+	}))
+	return p
+}
+
+// To 添加输出。
+func (p *Pipeline[T]) To(sinks ...Sink[T]) *Pipeline[T] {
+	for _, s := range sinks {
+		p.h.AddSink(SinkOf(s))
+	}
+	return p
+}
+
+// Build 返回底层的 handlers.Handlers，可以继续使用动态 API 配置。
+func (p *Pipeline[T]) Build() *handlers.Handlers {
+	return p.h
+}
+
+// Run 执行处理链。
+func (p *Pipeline[T]) Run() error {
+	return p.h.Run()
+}
+
+// Collect 添加一个收集所有输出的输出后执行处理链，返回收集到的数据，只应调用一次。
+func (p *Pipeline[T]) Collect() ([]T, error) {
+	c := &collector[T]{}
+	p.h.AddSink(SinkOf[T](c))
+	err := p.h.Run()
+	return c.out, err
+}
+
+type collector[T any] struct{ out []T }
+
+func (c *collector[T]) Write(out T) error { c.out = append(c.out, out); return nil }
+func (c *collector[T]) Close() error      { return nil }
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+)
+
+// TypedHandler 可选接口，声明了输入输出类型的处理器，用于严格模式下的类型检查。
+type TypedHandler interface {
+	Handler
+	// InType 返回接受的输入类型，nil 表示任意类型。
+	InType() reflect.Type
+	// OutType 返回输出的类型，nil 表示任意类型。
+	OutType() reflect.Type
+}
+
+type typedFunc struct {
+	fn      reflect.Value
+	in, out reflect.Type
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// TypedFunc 把形如 func(In) (Out, error) 的函数转换为 TypedHandler，fn 不符合要求时 panic。
+func TypedFunc(fn interface{}) TypedHandler {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.NumOut() != 2 || t.Out(1) != errorType {
+		panic(fmt.Sprintf("handlers: TypedFunc needs func(In) (Out, error), got %s", t))
+	}
+	return &typedFunc{fn: v, in: t.In(0), out: t.Out(0)}
+}
+
+func (tf *typedFunc) InType() reflect.Type  { return tf.in }
+func (tf *typedFunc) OutType() reflect.Type { return tf.out }
+func (tf *typedFunc) String() string        { return "TypedFunc(" + tf.fn.Type().String() + ")" }
+
+// Handle 实现 Handler 接口。
+func (tf *typedFunc) Handle(in interface{}) (interface{}, error) {
+	arg := reflect.Zero(tf.in)
+	if in != nil {
+		v := reflect.ValueOf(in)
+		if !v.Type().AssignableTo(tf.in) {
+			return nil, fmt.Errorf("handler %s expects %s, got %T", tf, tf.in, in)
+		}
+		arg = v
+	}
+	ret := tf.fn.Call([]reflect.Value{arg})
+	err, _ := ret[1].Interface().(error)
+	return ret[0].Interface(), err
+}
+
+// SetStrict 设置严格模式。严格模式下 Run 开始前调用 Validate，
+// 运行时检查交给 TypedHandler 的数据是否符合其声明的输入类型。
+func (h *Handlers) SetStrict(strict bool) {
+	var v int32
+	if strict {
+		v = 1
+	}
+	atomic.StoreInt32(&h.strict, v)
+}
+
+func (h *Handlers) isStrict() bool {
+	return atomic.LoadInt32(&h.strict) == 1
+}
+
+// Validate 检查相邻的 TypedHandler 声明的类型是否兼容，没有声明类型的处理器不做检查。
+func (h *Handlers) Validate() error {
+	if h.handlers == nil {
+		return nil
+	}
+	h.handlers.RLock()
+	defer h.handlers.RUnlock()
+	var prev TypedHandler
+	prevIndex := 0
+	i := 0
+	for e := h.handlers.Front(); e != nil; e, i = e.Next(), i+1 {
+		cur, ok := e.Value.(TypedHandler)
+		if !ok {
+			prev = nil
+			continue
+		}
+		if prev != nil {
+			out, in := prev.OutType(), cur.InType()
+			if out != nil && in != nil && !out.AssignableTo(in) {
+				return fmt.Errorf("handler %d (%s) expects %s, handler %d (%s) emits %s",
+					i, handlerName(cur), in, prevIndex, handlerName(prev), out)
+			}
+		}
+		prev, prevIndex = cur, i
+	}
+	return nil
+}
+
+// checkInType 严格模式下检查 in 是否符合 th 声明的输入类型。
+func checkInType(th TypedHandler, in interface{}) error {
+	want := th.InType()
+	if want == nil {
+		return nil
+	}
+	if in == nil {
+		switch want.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return nil
+		}
+		return fmt.Errorf("handler %s expects %s, got nil", handlerName(th), want)
+	}
+	if got := reflect.TypeOf(in); !got.AssignableTo(want) {
+		return fmt.Errorf("handler %s expects %s, got %s", handlerName(th), want, got)
+	}
+	return nil
+}
+
+// handlerName 返回用于错误信息的处理器名称。
+func handlerName(h Handler) string {
+	if s, ok := h.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", h)
+}
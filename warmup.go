@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"time"
+)
+
+// Warmer 可选接口，处理器实现它以在处理第一条数据前完成预加载，
+// 例如加载查找表、编译正则、预热缓存等。
+type Warmer interface {
+	Warmup(ctx context.Context) error
+}
+
+// Warmup 按处理链的顺序预热所有实现了 Warmer 的处理器，返回预热的总耗时。
+// 应在 Run 之前调用，遇到错误或 ctx 结束时立即返回。
+func (h *Handlers) Warmup(ctx context.Context) (elapsed time.Duration, err error) {
+	start := time.Now()
+	if h.handlers == nil {
+		return 0, nil
+	}
+	h.handlers.RLock()
+	defer h.handlers.RUnlock()
+	for e := h.handlers.Front(); e != nil; e = e.Next() {
+		if err = ctx.Err(); err != nil {
+			return time.Since(start), err
+		}
+		w, ok := e.Value.(Warmer)
+		if !ok {
+			continue
+		}
+		if err = w.Warmup(ctx); err != nil {
+			return time.Since(start), err
+		}
+	}
+	return time.Since(start), nil
+}
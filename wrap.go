@@ -0,0 +1,64 @@
+package handlers
+
+import "context"
+
+// stage 包装一个处理器，转发 Handle 以外的可选接口。需要包装处理器的功能嵌入它，只实现 Handle。
+type stage struct {
+	h Handler
+}
+
+// unwrap 返回被包装的处理器。
+func (s stage) unwrap() Handler { return s.h }
+
+// String 返回被包装的处理器的名称。
+func (s stage) String() string { return handlerName(s.h) }
+
+// HandleMarker 实现 MarkerHandler 接口。
+func (s stage) HandleMarker(m Marker) (interface{}, error) {
+	if mh, ok := s.h.(MarkerHandler); ok {
+		return mh.HandleMarker(m)
+	}
+	return nil, nil
+}
+
+// Flush 实现 Flusher 接口。
+func (s stage) Flush() error {
+	if f, ok := s.h.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Warmup 实现 Warmer 接口。
+func (s stage) Warmup(ctx context.Context) error {
+	if w, ok := s.h.(Warmer); ok {
+		return w.Warmup(ctx)
+	}
+	return nil
+}
+
+// SetSideEmitter 实现 SideOutputHandler 接口。
+func (s stage) SetSideEmitter(emit SideEmitter) {
+	if sh, ok := s.h.(SideOutputHandler); ok {
+		sh.SetSideEmitter(emit)
+	}
+}
+
+// Health 实现 HealthChecker 接口。
+func (s stage) Health() error {
+	if hc, ok := s.h.(HealthChecker); ok {
+		return hc.Health()
+	}
+	return nil
+}
+
+// unwrapHandler 展开所有包装，返回最内层的处理器。
+func unwrapHandler(v Handler) Handler {
+	for {
+		u, ok := v.(interface{ unwrap() Handler })
+		if !ok {
+			return v
+		}
+		v = u.unwrap()
+	}
+}